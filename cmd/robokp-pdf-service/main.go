@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/cart"
 	conf "github.com/romapopov1212/robokp-pdf-service/internal/config"
 	db2 "github.com/romapopov1212/robokp-pdf-service/internal/db"
 	"github.com/romapopov1212/robokp-pdf-service/internal/handler"
+	"github.com/romapopov1212/robokp-pdf-service/internal/jobs"
+	"github.com/romapopov1212/robokp-pdf-service/internal/migrate"
 	"github.com/romapopov1212/robokp-pdf-service/internal/pdfgen"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/queue"
 	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"github.com/romapopov1212/robokp-pdf-service/internal/retention"
 	"github.com/romapopov1212/robokp-pdf-service/internal/service"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"github.com/romapopov1212/robokp-pdf-service/internal/webhook"
 	"go.uber.org/zap"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -41,50 +52,172 @@ func main() {
 		log.Fatalf("error init database connection: %v", err)
 	}
 	
-	repo, err := repository.New(db)
-	if err != nil {
-		log.Fatalf("error create table: %v", err)
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(db, flag.Args()[1:]); err != nil {
+			log.Fatalf("error migrate: %v", err)
+		}
+		return
+	}
+	if cfg.Database.AutoMigrate {
+		applied, err := migrate.Up(context.Background(), db)
+		if err != nil {
+			log.Fatalf("error migrate: %v", err)
+		}
+		logger.Info("migrations applied", zap.Ints("versions", applied))
 	}
 	
+	repo := repository.New(db)
+	
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("error trusted proxies: %v", err)
+	}
 	
-	awsCfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(cfg.AWS.Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			cfg.AWS.AccessKeyID,
-			cfg.AWS.SecretAccessKey,
-			"",
-		)),
-		config.WithEndpointResolverWithOptions(
-			aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-				return aws.Endpoint{
-					URL:           cfg.AWS.EndpointUri,
-					SigningRegion: cfg.AWS.Region,
-				}, nil
-			}),
-		),
-	)
+	store, err := storage.New(context.Background(), cfg.Storage, cfg.AWS)
+	if err != nil {
+		log.Fatalf("error init storage: %v", err)
+	}
+	if local, ok := store.(*storage.Local); ok {
+		router.GET("storage/*key", gin.WrapH(http.StripPrefix("/storage", local)))
+	}
 	
+	sweeper, err := retention.New(repo, store, logger, cfg.AWS.UploadDir, cfg.Retention)
 	if err != nil {
-		log.Fatalf("error loading AWS config: %v", err)
+		log.Fatalf("error init retention: %v", err)
+	}
+	if flag.Arg(0) == "sweep" {
+		if err := runSweep(sweeper, flag.Args()[1:]); err != nil {
+			log.Fatalf("error sweep: %v", err)
+		}
+		return
 	}
 	
-	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
-		o.UsePathStyle = true
-	})
+	var carts pdfgen.CartSource
+	if cfg.Cart.BaseURL != "" {
+		carts = cart.New(cfg.Cart)
+	}
 	
-	pd := pdfgen.New(s3Client, cfg.AWS.Bucket, cfg.AWS.Region, cfg.AWS.UploadDir)
+	images := pdfgen.NewImageFetcher(store, cfg.AWS.Bucket, cfg.Images)
+	
+	pd, err := pdfgen.New(store, cfg.AWS.UploadDir, carts, repo, images)
+	if err != nil {
+		log.Fatalf("error init pdf generator: %v", err)
+	}
 	
 	fmt.Println(cfg.AWS.Bucket, cfg.AWS.Region, cfg.AWS.UploadDir)
 	
-	srv := service.NewPdfService(repo, logger, s3Client, pd)
+	srv := service.NewPdfService(repo, logger, store, pd, cfg.Publication, cfg.Download)
+	
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	
+	q := queue.New(db, cfg.Queue)
+	notifier, err := webhook.New(q, repo, logger, cfg.Webhook)
+	if err != nil {
+		log.Fatalf("error init webhook notifier: %v", err)
+	}
+	notifier.Start(ctx)
+	
+	runner := jobs.New(repo, pd, q, notifier, logger, cfg.Jobs)
+	if err := runner.Start(ctx); err != nil {
+		log.Fatalf("error start jobs: %v", err)
+	}
+	
+	if cfg.Retention.Enabled {
+		sweeper.Start(ctx)
+	}
 	
-	handler.RegisterRoutes(srv, router, logger, pd)
+	handler.RegisterRoutes(srv, router, logger, pd, runner, notifier)
 	
 	servAddr := cfg.Address
 	
+	server := &http.Server{
+		Addr:    servAddr,
+		Handler: router,
+	}
+	
+	go func() {
+		<-ctx.Done()
+		logger.Info("stopping server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("failed to stop server", zap.Error(err))
+		}
+	}()
+	
 	logger.Info("stating server", zap.String("address", servAddr))
-	if err := router.Run(servAddr); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Fatal("failed to start server", zap.Error(err))
 	}
+	
+	runner.Wait()
+	notifier.Wait()
+	sweeper.Wait()
+}
+
+// runMigrate команда migrate: up, down [шагов, по умолчанию 1], status
+func runMigrate(db *sql.DB, args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+	
+	switch args[0] {
+	case "up":
+		applied, err := migrate.Up(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Println("applied:", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrate.Down(ctx, db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Println("reverted:", reverted)
+	case "status":
+		statuses, err := migrate.List(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			fmt.Printf("%04d_%s applied=%t\n", s.Version, s.Name, s.Applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	
+	return nil
+}
+
+// runSweep выполняет один проход очистки PDF: sweep [dry-run]
+func runSweep(sweeper *retention.Sweeper, args []string) error {
+	dryRun := false
+	if len(args) > 0 {
+		if args[0] != "dry-run" {
+			return errors.New("usage: sweep [dry-run]")
+		}
+		dryRun = true
+	}
+	
+	report, err := sweeper.Sweep(context.Background(), dryRun)
+	if err != nil {
+		return err
+	}
+	for _, obj := range report.Removed {
+		fmt.Printf("%s\t%d\t%s\n", obj.Key, obj.Size, obj.ModTime.Format(time.RFC3339))
+	}
+	fmt.Printf("dry_run=%t scanned=%d referenced=%d removed=%d bytes=%d\n",
+		report.DryRun, report.Scanned, report.Referenced, len(report.Removed), report.Bytes)
+	
+	return nil
 }
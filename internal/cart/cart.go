@@ -0,0 +1,62 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var ErrNotFound = errors.New("корзина не найдена")
+
+// Client загружает содержимое корзины из сервиса корзин по id_cart
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func New(cfg config.CartConfig) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (c *Client) GetCart(ctx context.Context, cartId int64) (*dto.Cart, error) {
+	const op = "cart.GetCart"
+
+	url := c.baseURL + "/" + strconv.FormatInt(cartId, 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: ошибка запроса корзины: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: сервис корзин вернул %d", op, resp.StatusCode)
+	}
+
+	var cart dto.Cart
+	if err := json.NewDecoder(resp.Body).Decode(&cart); err != nil {
+		return nil, fmt.Errorf("%s: ошибка разбора корзины: %w", op, err)
+	}
+	if cart.ID == 0 {
+		cart.ID = cartId
+	}
+
+	return &cart, nil
+}
@@ -7,10 +7,19 @@ import (
 )
 
 type Config struct {
-	Env        string `mapstructure:"env"`
-	Database   `mapstructure:"database"`
-	HttpServer `mapstructure:"http_server"`
-	AWS        AWSConfig `mapstructure:"aws"`
+	Env         string `mapstructure:"env"`
+	Database    `mapstructure:"database"`
+	HttpServer  `mapstructure:"http_server"`
+	AWS         AWSConfig         `mapstructure:"aws"`
+	Storage     StorageConfig     `mapstructure:"storage"`
+	Cart        CartConfig        `mapstructure:"cart"`
+	Images      ImagesConfig      `mapstructure:"images"`
+	Jobs        JobsConfig        `mapstructure:"jobs"`
+	Queue       QueueConfig       `mapstructure:"queue"`
+	Webhook     WebhookConfig     `mapstructure:"webhook"`
+	Publication PublicationConfig `mapstructure:"publication"`
+	Download    DownloadConfig    `mapstructure:"download"`
+	Retention   RetentionConfig   `mapstructure:"retention"`
 }
 
 type Database struct {
@@ -19,12 +28,17 @@ type Database struct {
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
 	Name     string `mapstructure:"name"`
+	// AutoMigrate применять миграции при старте, иначе только командой migrate
+	AutoMigrate bool `mapstructure:"auto_migrate"`
 }
 
 type HttpServer struct {
 	Address     string        `mapstructure:"address"`
 	Timeout     time.Duration `mapstructure:"timeout"`
 	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// TrustedProxies адреса и подсети прокси, которым доверяется X-Forwarded-For.
+	// Пусто - адрес клиента берется из соединения
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type AWSConfig struct {
@@ -34,6 +48,86 @@ type AWSConfig struct {
 	AccessKeyID     string `mapstructure:"access_key_id"`     // опционально
 	SecretAccessKey string `mapstructure:"secret_access_key"` // опционально
 	EndpointUri     string `mapstructure:"endpoint_uri"`
+	// PublicEndpoint адрес MinIO, доступный клиентам, на него подписываются presigned URL.
+	// Пусто - используется EndpointUri
+	PublicEndpoint string `mapstructure:"public_endpoint"`
+	// UploadPartSizeMB размер части multipart загрузки, не меньше 5. Столько памяти на каждую
+	// параллельную часть занимает загрузка
+	UploadPartSizeMB  int64 `mapstructure:"upload_part_size_mb"`
+	UploadConcurrency int   `mapstructure:"upload_concurrency"` // сколько частей загружается параллельно
+}
+
+type StorageConfig struct {
+	Backend string             `mapstructure:"backend"` // s3 (по умолчанию, параметры в aws), local, memory
+	Local   LocalStorageConfig `mapstructure:"local"`
+}
+
+type LocalStorageConfig struct {
+	Dir     string `mapstructure:"dir"`
+	BaseURL string `mapstructure:"base_url"` // адрес, по которому сервис отдает файлы, для ссылок на скачивание
+	Secret  string `mapstructure:"secret"`   // ключ подписи ссылок, не короче 16 байт
+}
+
+type CartConfig struct {
+	BaseURL string        `mapstructure:"base_url"` // адрес сервиса корзин, пусто - только корзина из запроса
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type ImagesConfig struct {
+	KeyPrefix    string        `mapstructure:"key_prefix"`    // префикс ключей в бакете, по которому ссылки на картинки отличаются от base64
+	AllowedHosts []string      `mapstructure:"allowed_hosts"` // хосты, с которых разрешено скачивать картинки по http(s)
+	MaxSize      int64         `mapstructure:"max_size"`      // максимальный размер картинки в байтах
+	MaxPixels    int64         `mapstructure:"max_pixels"`    // максимальное число пикселей, проверяется по заголовку до декодирования
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+type JobsConfig struct {
+	Workers      int           `mapstructure:"workers"`       // число параллельных генераций на реплику
+	PollInterval time.Duration `mapstructure:"poll_interval"` // пауза между опросами пустой очереди
+	Timeout      time.Duration `mapstructure:"timeout"`       // максимальное время одной генерации
+}
+
+type QueueConfig struct {
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout"` // на сколько взятая задача скрыта от других реплик
+	MaxAttempts       int           `mapstructure:"max_attempts"`       // после стольких попыток задача уходит в dead
+	BaseBackoff       time.Duration `mapstructure:"base_backoff"`       // задержка перед первым повтором, дальше удваивается
+	MaxBackoff        time.Duration `mapstructure:"max_backoff"`
+}
+
+type PublicationConfig struct {
+	BaseURL    string        `mapstructure:"base_url"`    // публичный адрес сервиса, из него собираются ссылки /p/:slug
+	DefaultTTL time.Duration `mapstructure:"default_ttl"` // срок ссылки, если не задан в запросе, 0 - бессрочно
+	Redirect   bool          `mapstructure:"redirect"`    // отдавать редирект на presigned URL вместо потоковой отдачи
+	PresignTTL time.Duration `mapstructure:"presign_ttl"` // время жизни presigned URL при редиректе
+	// IPHashSalt соль для хеша IP в журнале просмотров, сами адреса не хранятся
+	IPHashSalt string `mapstructure:"ip_hash_salt"`
+	// NotifyFirstView отправлять webhook pdf.first_viewed на адрес по умолчанию при первом открытии ссылки
+	NotifyFirstView bool `mapstructure:"notify_first_view"`
+}
+
+type DownloadConfig struct {
+	DefaultTTL time.Duration `mapstructure:"default_ttl"` // время жизни ссылки на скачивание, если ttl не передан
+	MaxTTL     time.Duration `mapstructure:"max_ttl"`     // верхняя граница ttl из запроса
+}
+
+// RetentionConfig очистка сгенерированных PDF в upload_dir, на которые больше ничего не ссылается
+type RetentionConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Interval    time.Duration `mapstructure:"interval"`      // период запуска очистки
+	KeepPerCart int           `mapstructure:"keep_per_cart"` // сколько последних ревизий корзины хранить, 0 - все
+	// MaxAge объекты без ссылок удаляются, только если старше MaxAge. Столько же хранятся результаты задач
+	MaxAge time.Duration `mapstructure:"max_age"`
+	DryRun bool          `mapstructure:"dry_run"` // только писать в лог, что было бы удалено
+}
+
+type WebhookConfig struct {
+	DefaultURL   string        `mapstructure:"default_url"`   // куда отправлять уведомления, если в запросе нет callback_url
+	Secret       string        `mapstructure:"secret"`        // ключ подписи HMAC-SHA256, обязателен
+	Workers      int           `mapstructure:"workers"`       // число параллельных отправок на реплику
+	PollInterval time.Duration `mapstructure:"poll_interval"` // пауза между опросами пустой очереди
+	Timeout      time.Duration `mapstructure:"timeout"`       // таймаут одного запроса к получателю
+	// AllowPrivateNetworks разрешить отправку на loopback, link-local и частные адреса, только для разработки
+	AllowPrivateNetworks bool `mapstructure:"allow_private_networks"`
 }
 
 func LoadConfig(path string) (Config, error) {
@@ -1,6 +1,9 @@
 package dto
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type SavePdfRequest struct {
 	UserId                 int64
@@ -34,6 +37,15 @@ type ExecutorParam struct {
 	ShowContacts string `json:"show_contacts"`
 }
 
+// Executor профиль исполнителя (менеджера), который выводится в колонтитулах КП
+type Executor struct {
+	UserId      int64  `json:"id_user"`
+	CompanyName string `json:"company_name"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email"`
+	Logo        string `json:"logo,omitempty"`
+}
+
 type ExecutorParameters struct {
 	First ExecutorParam `json:"first"`
 	All   ExecutorParam `json:"all"`
@@ -52,6 +64,51 @@ type StyleTemplate struct {
 	Color      string `json:"color,omitempty"` // если есть
 }
 
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// PrintArea область нанесения логотипа на изображении товара, координаты в пикселях
+type PrintArea struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation,omitempty"` // градусы по часовой стрелке вокруг центра
+	Quad     []Point `json:"quad,omitempty"`     // перспектива: углы от левого верхнего по часовой стрелке
+}
+
+type LineItem struct {
+	Name        string     `json:"name"`
+	SKU         string     `json:"sku"`
+	Image       string     `json:"image"`
+	MockupImage string     `json:"mockup_image,omitempty"`
+	PrintArea   *PrintArea `json:"print_area,omitempty"` // если задана, мокап собирается из image и логотипа клиента
+	Description string     `json:"description,omitempty"`
+	UnitPrice   float64    `json:"unit_price"`
+	Quantity    int        `json:"quantity"`
+}
+
+// Total стоимость позиции с учетом количества
+func (i LineItem) Total() float64 {
+	return i.UnitPrice * float64(i.Quantity)
+}
+
+type Cart struct {
+	ID    int64      `json:"id"`
+	Items []LineItem `json:"items"`
+}
+
+// Total итоговая стоимость всех позиций корзины
+func (c Cart) Total() float64 {
+	var total float64
+	for _, item := range c.Items {
+		total += item.Total()
+	}
+	return total
+}
+
 type SaveRequest struct {
 	UserId                 int64                  `json:"id_user"`
 	CartId                 int64                  `json:"id_cart"`
@@ -61,4 +118,187 @@ type SaveRequest struct {
 	PresentationParameters PresentationParameters `json:"presentation_parameters"`
 	StyleTemplate          StyleTemplate          `json:"style_template"`
 	Count                  int                    `json:"count"`
+	Cart                   *Cart                  `json:"cart,omitempty"`         // если не передана, загружается по id_cart
+	CallbackURL            string                 `json:"callback_url,omitempty"` // куда отправить уведомление о готовности
+}
+
+type GeneratePdfResponse struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	URL    string `json:"url"`
+	// InputHash хеш входных данных: одинаковые запросы дают одинаковый хеш и ключ
+	InputHash    string `json:"input_hash"`
+	Deduplicated bool   `json:"deduplicated"` // PDF взят из хранилища без повторной отрисовки
+}
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job задача асинхронной генерации КП
+type Job struct {
+	ID         int64           `json:"id"`
+	Status     JobStatus       `json:"status"`
+	Request    json.RawMessage `json:"-"`
+	Key        string          `json:"key,omitempty"`
+	Size       int64           `json:"size,omitempty"`
+	SHA256     string          `json:"sha256,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+type WebhookEvent string
+
+const (
+	WebhookPdfSucceeded WebhookEvent = "pdf.succeeded"
+	WebhookPdfFailed    WebhookEvent = "pdf.failed"
+	WebhookPdfViewed    WebhookEvent = "pdf.first_viewed"
+)
+
+// WebhookPayload тело уведомления о завершении генерации КП
+type WebhookPayload struct {
+	Event         WebhookEvent `json:"event"`
+	JobID         int64        `json:"job_id,omitempty"`
+	CartId        int64        `json:"id_cart"`
+	PublicationId int64        `json:"id_publication"`
+	Key           string       `json:"key,omitempty"`
+	URL           string       `json:"url,omitempty"`
+	Size          int64        `json:"size,omitempty"`
+	SHA256        string       `json:"sha256,omitempty"`
+	InputHash     string       `json:"input_hash,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	Slug          string       `json:"slug,omitempty"` // публикация, которую открыли, для pdf.first_viewed
+	OccurredAt    time.Time    `json:"occurred_at"`
+}
+
+// WebhookDelivery попытка отправки уведомления, пишется в журнал webhook_delivery
+type WebhookDelivery struct {
+	TaskID     int64
+	JobID      int64
+	Event      WebhookEvent
+	URL        string
+	Attempt    int
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// SavedPdf сохраненная конфигурация КП из pdf_kp
+type SavedPdf struct {
+	ID int64 `json:"id"`
+	SaveRequest
+	PublicationURL string    `json:"publication_url,omitempty"`
+	SaveRequired   bool      `json:"save_required"`
+	Version        int       `json:"version"` // растет при каждом изменении, отдается в ETag
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PdfFilter условия выборки pdf_kp. Нулевые поля не фильтруют
+type PdfFilter struct {
+	UserId int64
+	CartId int64
+	From   *time.Time // created_at >= From
+	To     *time.Time // created_at < To
+	Cursor string     // next_cursor из предыдущей страницы
+	Limit  int
+}
+
+// PdfUpdate изменяемая часть сохраненного КП: тело PUT и цель merge patch в PATCH
+type PdfUpdate struct {
+	Logo                   Logo                   `json:"logo"`
+	ExecutorParameters     ExecutorParameters     `json:"executor_parameters"`
+	PresentationParameters PresentationParameters `json:"presentation_parameters"`
+	StyleTemplate          StyleTemplate          `json:"style_template"`
+	Count                  int                    `json:"count"`
+}
+
+type SavedPdfPage struct {
+	Items      []SavedPdf `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Revision сгенерированный документ КП
+type Revision struct {
+	ID         int64           `json:"id"`
+	PdfID      int64           `json:"pdf_id"`
+	Number     int             `json:"number"` // порядковый номер в пределах КП, с 1
+	Key        string          `json:"key"`
+	SHA256     string          `json:"sha256"`
+	Size       int64           `json:"size"`
+	Pages      int             `json:"pages"`
+	TemplateID string          `json:"template_id"`
+	Input      json.RawMessage `json:"input,omitempty"` // запрос с корзиной, по которому отрисован документ
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// RevisionChange отличие входных данных двух ревизий, path в формате JSON Pointer
+type RevisionChange struct {
+	Op   string `json:"op"` // add, remove, replace
+	Path string `json:"path"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+type RevisionDiff struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []RevisionChange `json:"changes"`
+}
+
+// Publication публичная ссылка на КП
+type Publication struct {
+	ID        int64      `json:"id"`
+	PdfID     int64      `json:"pdf_id"`
+	Slug      string     `json:"slug"`
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Revoked   bool       `json:"revoked"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type PublishRequest struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // если не задан, срок берется из конфига
+}
+
+// View открытие публичной ссылки, IP хранится только в виде хеша
+type View struct {
+	IPHash    string
+	UserAgent string
+	Referer   string
+}
+
+type PublicationStats struct {
+	Slug          string     `json:"slug"`
+	Views         int64      `json:"views"`
+	FirstViewedAt *time.Time `json:"first_viewed_at,omitempty"`
+	LastViewedAt  *time.Time `json:"last_viewed_at,omitempty"`
+}
+
+// ViewStats просмотры КП по всем его публикациям
+type ViewStats struct {
+	PdfID         int64              `json:"pdf_id"`
+	Views         int64              `json:"views"`
+	UniqueViewers int64              `json:"unique_viewers"` // по хешу IP
+	FirstViewedAt *time.Time         `json:"first_viewed_at,omitempty"`
+	LastViewedAt  *time.Time         `json:"last_viewed_at,omitempty"`
+	Publications  []PublicationStats `json:"publications"`
+}
+
+// DownloadURL временная ссылка на скачивание последней ревизии КП
+type DownloadURL struct {
+	URL       string    `json:"url"`
+	Filename  string    `json:"filename"`
+	Revision  int       `json:"revision"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"github.com/romapopov1212/robokp-pdf-service/internal/service"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DownloadURL GET api/v1/pdf/:id/download-url?ttl= временная ссылка на скачивание последней ревизии КП.
+// ttl - длительность (15m, 1h) или число секунд
+func (h *Controller) DownloadURL(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+
+	var ttl time.Duration
+	if raw := c.Query("ttl"); raw != "" {
+		ttl, err = parseTTL(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный ttl"})
+			return
+		}
+	}
+
+	link, err := h.pdfService.DownloadURL(c.Request.Context(), id, ttl)
+	if errors.Is(err, service.ErrInvalidTTL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено или еще не сгенерировано"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка создания ссылки на скачивание", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось создать ссылку"})
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+func parseTTL(raw string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(raw)
+}
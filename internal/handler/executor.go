@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"net/http"
+	"strconv"
+)
+
+func (h *Controller) SaveExecutor(c *gin.Context) {
+	var req dto.Executor
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный запрос"})
+		return
+	}
+	if req.UserId == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "не указан id_user"})
+		return
+	}
+
+	if err := h.pdfService.SaveExecutor(c.Request.Context(), req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось сохранить исполнителя"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "успешно сохранено"})
+}
+
+func (h *Controller) GetExecutor(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("id_user"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id_user"})
+		return
+	}
+
+	executor, err := h.pdfService.GetExecutor(c.Request.Context(), userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "исполнитель не найден"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить исполнителя"})
+		return
+	}
+
+	c.JSON(http.StatusOK, executor)
+}
@@ -2,20 +2,26 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/jobs"
 	"github.com/romapopov1212/robokp-pdf-service/internal/pdfgen"
 	"github.com/romapopov1212/robokp-pdf-service/internal/service"
+	"github.com/romapopov1212/robokp-pdf-service/internal/webhook"
 	"go.uber.org/zap"
 )
 
 type Controller struct {
 	pdfGenService *pdfgen.Page
+	jobs          *jobs.Runner
+	notifier      *webhook.Notifier
 	pdfService    *service.PdfService
 	router        *gin.Engine
 	logger        *zap.Logger
 }
 
-func RegisterRoutes(pdfService *service.PdfService, router *gin.Engine, logger *zap.Logger, pdfGenService *pdfgen.Page) Controller {
+func RegisterRoutes(pdfService *service.PdfService, router *gin.Engine, logger *zap.Logger, pdfGenService *pdfgen.Page, jobs *jobs.Runner, notifier *webhook.Notifier) Controller {
 	cntrl := Controller{
+		jobs:          jobs,
+		notifier:      notifier,
 		pdfService:    pdfService,
 		router:        router,
 		logger:        logger,
@@ -23,7 +29,25 @@ func RegisterRoutes(pdfService *service.PdfService, router *gin.Engine, logger *
 	}
 	
 	cntrl.router.POST("api/v1/pdf", cntrl.SavePdf)
+	cntrl.router.GET("api/v1/pdf", cntrl.ListPdf)
+	cntrl.router.GET("api/v1/pdf/:id", cntrl.GetPdf)
+	cntrl.router.PUT("api/v1/pdf/:id", cntrl.UpdatePdf)
+	cntrl.router.PATCH("api/v1/pdf/:id", cntrl.PatchPdf)
+	cntrl.router.DELETE("api/v1/pdf/:id", cntrl.DeletePdf)
+	cntrl.router.POST("api/v1/pdf/:id/render", cntrl.RenderPdf)
+	cntrl.router.GET("api/v1/pdf/:id/revisions", cntrl.ListRevisions)
+	cntrl.router.GET("api/v1/pdf/:id/revisions/diff", cntrl.DiffRevisions)
+	cntrl.router.GET("api/v1/pdf/:id/revisions/:number", cntrl.GetRevision)
 	cntrl.router.POST("api/v1/pdfGen", cntrl.GeneratePdf)
+	cntrl.router.POST("api/v1/pdf/jobs", cntrl.CreateJob)
+	cntrl.router.GET("api/v1/pdf/jobs/:id", cntrl.GetJob)
+	cntrl.router.POST("api/v1/pdf/:id/publish", cntrl.Publish)
+	cntrl.router.GET("api/v1/pdf/:id/stats", cntrl.ViewStats)
+	cntrl.router.GET("api/v1/pdf/:id/download-url", cntrl.DownloadURL)
+	cntrl.router.DELETE("api/v1/publications/:slug", cntrl.RevokePublication)
+	cntrl.router.GET("p/:slug", cntrl.ServePublication)
+	cntrl.router.PUT("api/v1/executor", cntrl.SaveExecutor)
+	cntrl.router.GET("api/v1/executor/:id_user", cntrl.GetExecutor)
 	
 	return cntrl
 }
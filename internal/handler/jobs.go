@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+func (h *Controller) CreateJob(c *gin.Context) {
+	var req dto.SaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный запрос"})
+		return
+	}
+	if err := h.notifier.ValidateURL(c.Request.Context(), req.CallbackURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.jobs.Submit(c.Request.Context(), req)
+	if err != nil {
+		h.logger.Error("ошибка создания задачи", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось создать задачу"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *Controller) GetJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+
+	job, err := h.jobs.Get(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "задача не найдена"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка получения задачи", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить задачу"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
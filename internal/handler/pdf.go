@@ -1,30 +1,90 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/cart"
 	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/pdfgen"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"github.com/romapopov1212/robokp-pdf-service/internal/service"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const mimePDF = "application/pdf"
+
 func (h *Controller) GeneratePdf(c *gin.Context) {
 	var req dto.SaveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный запрос"})
 		return
 	}
+	if err := h.notifier.ValidateURL(c.Request.Context(), req.CallbackURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	
-	//filename := "generated_" + strconv.FormatInt(req.UserId, 10) + ".pdf"
-	
-	err := h.pdfGenService.GenerateAdvancedPDFWithGofpdf(req)
+	res, err := h.pdfGenService.GenerateAdvancedPDFWithGofpdf(c.Request.Context(), req)
+	if err != nil && !errors.Is(err, pdfgen.ErrValidation) && !errors.Is(err, cart.ErrNotFound) {
+		h.notifyGenerated(c, req, nil, err)
+	}
+	if errors.Is(err, pdfgen.ErrValidation) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, cart.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.Error("ошибка генерации PDF", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка генерации PDF"})
 		return
 	}
+	h.notifyGenerated(c, req, res, nil)
 	
-	//c.FileAttachment(filename, "document.pdf") // если раскоментить будет ошибка 404 потому что он попыта
+	if c.NegotiateFormat(gin.MIMEJSON, mimePDF) == mimePDF {
+		h.writePdf(c, res)
+		return
+	}
+	
+	c.JSON(http.StatusOK, generateResponse(res))
+}
+
+// writePdf отдает содержимое КП. Если PDF выведен сразу в хранилище, Content пуст и PDF читается оттуда
+func (h *Controller) writePdf(c *gin.Context, res *pdfgen.Result) {
+	const disposition = `attachment; filename="document.pdf"`
+	if res.Content != nil {
+		c.Header("Content-Disposition", disposition)
+		c.Data(http.StatusOK, mimePDF, res.Content)
+		return
+	}
+	
+	body, obj, err := h.pdfGenService.Open(c.Request.Context(), res.Key)
+	if err != nil {
+		h.logger.Error("ошибка чтения PDF из хранилища", zap.Error(err), zap.String("key", res.Key))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка генерации PDF"})
+		return
+	}
+	defer body.Close()
+	c.DataFromReader(http.StatusOK, obj.Size, mimePDF, body, map[string]string{"Content-Disposition": disposition})
+}
+
+func generateResponse(res *pdfgen.Result) dto.GeneratePdfResponse {
+	return dto.GeneratePdfResponse{
+		Key:          res.Key,
+		Size:         res.Size,
+		SHA256:       res.SHA256,
+		URL:          res.URL,
+		InputHash:    res.InputHash,
+		Deduplicated: res.Deduplicated,
+	}
 }
 
 func (h *Controller) SavePdf(c *gin.Context) {
@@ -32,6 +92,7 @@ func (h *Controller) SavePdf(c *gin.Context) {
 	err := c.BindJSON(&req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 	
 	logoJson, err := json.Marshal(req.Logo)
@@ -58,7 +119,7 @@ func (h *Controller) SavePdf(c *gin.Context) {
 		return
 	}
 	
-	err = h.pdfService.SavePdf(c.Request.Context(), dto.SavePdfRequest{
+	id, err := h.pdfService.SavePdf(c.Request.Context(), dto.SavePdfRequest{
 		UserId:                 req.UserId,
 		CartId:                 req.CartId,
 		PublicationId:          req.PublicationId,
@@ -73,5 +134,283 @@ func (h *Controller) SavePdf(c *gin.Context) {
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{"status": "успешно сохранено"})
+	c.JSON(http.StatusCreated, gin.H{"id": id, "status": "успешно сохранено"})
+}
+
+// notifyGenerated ставит уведомление о результате синхронной генерации.
+// Об ошибках в самом запросе не уведомляем: вызывающий получает их в ответе.
+func (h *Controller) notifyGenerated(c *gin.Context, req dto.SaveRequest, res *pdfgen.Result, cause error) {
+	payload := dto.WebhookPayload{
+		Event:         dto.WebhookPdfSucceeded,
+		CartId:        req.CartId,
+		PublicationId: req.PublicationId,
+	}
+	if res != nil {
+		payload.Key = res.Key
+		payload.URL = res.URL
+		payload.Size = res.Size
+		payload.SHA256 = res.SHA256
+		payload.InputHash = res.InputHash
+	}
+	if cause != nil {
+		payload.Event = dto.WebhookPdfFailed
+		payload.Error = cause.Error()
+	}
+	
+	if err := h.notifier.Notify(context.WithoutCancel(c.Request.Context()), req.CallbackURL, payload); err != nil {
+		h.logger.Error("ошибка постановки уведомления", zap.Error(err))
+	}
+}
+
+func (h *Controller) GetPdf(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	
+	pdf, err := h.pdfService.GetPdf(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка получения КП", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить КП"})
+		return
+	}
+	
+	c.Header("ETag", etag(pdf.Version))
+	c.JSON(http.StatusOK, pdf)
+}
+
+// ListPdf GET api/v1/pdf?user_id=&cart_id=&from=&to=&cursor=&limit=, from и to в RFC 3339
+func (h *Controller) ListPdf(c *gin.Context) {
+	var filter dto.PdfFilter
+	var err error
+	
+	if v := c.Query("user_id"); v != "" {
+		if filter.UserId, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный user_id"})
+			return
+		}
+	}
+	if v := c.Query("cart_id"); v != "" {
+		if filter.CartId, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный cart_id"})
+			return
+		}
+	}
+	if filter.UserId == 0 && filter.CartId == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "нужен user_id или cart_id"})
+		return
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный " + p.name})
+			return
+		}
+		t = t.UTC()
+		*p.dst = &t
+	}
+	if v := c.Query("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный limit"})
+			return
+		}
+	}
+	filter.Cursor = c.Query("cursor")
+	
+	page, err := h.pdfService.ListPdf(c.Request.Context(), filter)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка получения списка КП", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить список КП"})
+		return
+	}
+	
+	c.JSON(http.StatusOK, page)
+}
+
+// UpdatePdf PUT api/v1/pdf/:id заменяет logo, executor_parameters, presentation_parameters,
+// style_template и count. Требует If-Match с ETag из GET
+func (h *Controller) UpdatePdf(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+	
+	var req dto.PdfUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный запрос"})
+		return
+	}
+	
+	pdf, err := h.pdfService.UpdatePdf(c.Request.Context(), id, version, req)
+	h.respondUpdated(c, pdf, err)
+}
+
+// PatchPdf PATCH api/v1/pdf/:id применяет JSON merge patch к тем же полям, что и PUT
+func (h *Controller) PatchPdf(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+	
+	patch, err := c.GetRawData()
+	if err != nil || !json.Valid(patch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный запрос"})
+		return
+	}
+	
+	pdf, err := h.pdfService.PatchPdf(c.Request.Context(), id, version, patch)
+	h.respondUpdated(c, pdf, err)
+}
+
+// DeletePdf DELETE api/v1/pdf/:id, If-Match необязателен
+func (h *Controller) DeletePdf(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	var version int
+	if c.GetHeader("If-Match") != "" {
+		var ok bool
+		if version, ok = requireIfMatch(c); !ok {
+			return
+		}
+	}
+	
+	err = h.pdfService.DeletePdf(c.Request.Context(), id, version)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка удаления КП", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось удалить КП"})
+		return
+	}
+	
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Controller) respondUpdated(c *gin.Context, pdf *dto.SavedPdf, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidPatch) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка обновления КП", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось обновить КП"})
+		return
+	}
+	
+	c.Header("ETag", etag(pdf.Version))
+	c.JSON(http.StatusOK, pdf)
+}
+
+// etag версия КП в виде сильного ETag
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// requireIfMatch достает версию из If-Match. Без заголовка отвечает 428, чтобы клиент
+// не перезаписал чужие изменения, с чужим форматом ETag - 412
+func requireIfMatch(c *gin.Context) (int, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "нужен заголовок If-Match"})
+		return 0, false
+	}
+	
+	value, err := strconv.Unquote(strings.TrimPrefix(strings.TrimSpace(header), "W/"))
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "невалидный If-Match"})
+		return 0, false
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "невалидный If-Match"})
+		return 0, false
+	}
+	return version, true
+}
+
+// RenderPdf POST api/v1/pdf/:id/render генерирует PDF по сохраненной конфигурации
+func (h *Controller) RenderPdf(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	
+	rendered, err := h.pdfService.RenderPdf(c.Request.Context(), id)
+	if rendered != nil && err != nil && !errors.Is(err, pdfgen.ErrValidation) && !errors.Is(err, cart.ErrNotFound) {
+		h.notifyGenerated(c, rendered.Pdf.SaveRequest, nil, err)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if errors.Is(err, repository.ErrVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "КП изменено во время генерации, повторите запрос"})
+		return
+	}
+	if errors.Is(err, pdfgen.ErrValidation) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, cart.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка генерации PDF", zap.Error(err), zap.Int64("id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка генерации PDF"})
+		return
+	}
+	res := rendered.Result
+	h.notifyGenerated(c, rendered.Pdf.SaveRequest, res, nil)
+	
+	c.Header("ETag", etag(rendered.Pdf.Version))
+	c.JSON(http.StatusOK, gin.H{
+		"pdf":      rendered.Pdf,
+		"revision": rendered.Revision,
+		"result":   generateResponse(res),
+	})
 }
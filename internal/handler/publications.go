@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"github.com/romapopov1212/robokp-pdf-service/internal/service"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// Publish POST api/v1/pdf/:id/publish создает публичную ссылку /p/:slug
+func (h *Controller) Publish(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+
+	var req dto.PublishRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный запрос"})
+			return
+		}
+	}
+
+	pub, err := h.pdfService.Publish(c.Request.Context(), id, req)
+	if errors.Is(err, service.ErrInvalidExpiry) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка публикации КП", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось опубликовать КП"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, pub)
+}
+
+// RevokePublication DELETE api/v1/publications/:slug
+func (h *Controller) RevokePublication(c *gin.Context) {
+	err := h.pdfService.RevokePublication(c.Request.Context(), c.Param("slug"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "публикация не найдена"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка отзыва публикации", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось отозвать публикацию"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ServePublication GET /p/:slug публичная ссылка на последнюю ревизию КП
+func (h *Controller) ServePublication(c *gin.Context) {
+	content, err := h.pdfService.OpenPublication(c.Request.Context(), c.Param("slug"), service.Viewer{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Referer:   c.Request.Referer(),
+	})
+	if errors.Is(err, service.ErrPublicationGone) {
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) || errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка открытия публикации", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось открыть КП"})
+		return
+	}
+
+	if content.FirstView != nil {
+		h.notifyFirstView(c, content.FirstView, content.Publication)
+	}
+
+	// Ссылку можно отозвать, поэтому ни ответ, ни редирект не кешируются
+	c.Header("Cache-Control", "no-store")
+	if content.RedirectURL != "" {
+		c.Redirect(http.StatusFound, content.RedirectURL)
+		return
+	}
+	defer content.Body.Close()
+
+	c.DataFromReader(http.StatusOK, content.Size, mimePDF, content.Body, map[string]string{
+		"Content-Disposition": `inline; filename="document.pdf"`,
+	})
+}
+
+// ViewStats GET api/v1/pdf/:id/stats просмотры КП по публичным ссылкам
+func (h *Controller) ViewStats(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+
+	stats, err := h.pdfService.ViewStats(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка получения статистики", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить статистику"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// notifyFirstView уведомление о первом открытии ссылки уходит на адрес webhook по умолчанию
+func (h *Controller) notifyFirstView(c *gin.Context, pdf *dto.SavedPdf, pub *dto.Publication) {
+	err := h.notifier.Notify(context.WithoutCancel(c.Request.Context()), "", dto.WebhookPayload{
+		Event:         dto.WebhookPdfViewed,
+		CartId:        pdf.CartId,
+		PublicationId: pub.ID,
+		Key:           pdf.PublicationURL,
+		Slug:          pub.Slug,
+	})
+	if err != nil {
+		h.logger.Error("ошибка постановки уведомления", zap.Error(err))
+	}
+}
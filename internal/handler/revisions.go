@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+func (h *Controller) ListRevisions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+
+	revisions, err := h.pdfService.ListRevisions(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "КП не найдено"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка получения ревизий", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить ревизии"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": revisions})
+}
+
+func (h *Controller) GetRevision(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный номер ревизии"})
+		return
+	}
+
+	rev, err := h.pdfService.GetRevision(c.Request.Context(), id, number)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ревизия не найдена"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка получения ревизии", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось получить ревизию"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+// DiffRevisions GET api/v1/pdf/:id/revisions/diff?from=&to= сравнивает входные данные двух ревизий
+func (h *Controller) DiffRevisions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный id"})
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный from"})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "невалидный to"})
+		return
+	}
+
+	diff, err := h.pdfService.DiffRevisions(c.Request.Context(), id, from, to)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ревизия не найдена"})
+		return
+	}
+	if err != nil {
+		h.logger.Error("ошибка сравнения ревизий", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "не удалось сравнить ревизии"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,329 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/pdfgen"
+	"github.com/romapopov1212/robokp-pdf-service/internal/queue"
+	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"github.com/romapopov1212/robokp-pdf-service/internal/webhook"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// KindGeneratePdf тип задачи генерации КП в queue_task
+const KindGeneratePdf = "pdf_generate"
+
+const (
+	defaultWorkers      = 2
+	defaultPollInterval = time.Second
+	defaultTimeout      = 5 * time.Minute
+)
+
+type payload struct {
+	JobID int64 `json:"job_id"`
+}
+
+// Runner выполняет задачи генерации КП пулом воркеров.
+// Задачи берутся из очереди в Postgres, поэтому их разбирают все реплики сервиса,
+// а задачи упавшей реплики подхватываются после таймаута видимости.
+type Runner struct {
+	repo         *repository.PdfRepository
+	pdfGen       *pdfgen.Page
+	queue        *queue.Queue
+	notifier     *webhook.Notifier
+	logger       *zap.Logger
+	workers      int
+	pollInterval time.Duration
+	timeout      time.Duration
+	wg           sync.WaitGroup
+}
+
+func New(repo *repository.PdfRepository, pdfGen *pdfgen.Page, q *queue.Queue, notifier *webhook.Notifier, logger *zap.Logger, cfg config.JobsConfig) *Runner {
+	r := &Runner{
+		repo:         repo,
+		pdfGen:       pdfGen,
+		queue:        q,
+		notifier:     notifier,
+		logger:       logger,
+		workers:      cfg.Workers,
+		pollInterval: cfg.PollInterval,
+		timeout:      cfg.Timeout,
+	}
+	if r.workers <= 0 {
+		r.workers = defaultWorkers
+	}
+	if r.pollInterval <= 0 {
+		r.pollInterval = defaultPollInterval
+	}
+	if r.timeout <= 0 {
+		r.timeout = defaultTimeout
+	}
+	
+	return r
+}
+
+// Start запускает воркеры. Воркеры останавливаются при отмене ctx, Wait дожидается их завершения.
+func (r *Runner) Start(ctx context.Context) error {
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.work(ctx)
+	}
+	
+	return nil
+}
+
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// Submit сохраняет задачу и ставит ее в очередь
+func (r *Runner) Submit(ctx context.Context, req dto.SaveRequest) (*dto.Job, error) {
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+	
+	id, err := r.repo.CreateJob(ctx, request, func(tx *sql.Tx, id int64) error {
+		_, err := r.queue.EnqueueTx(ctx, tx, KindGeneratePdf, payload{JobID: id})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return r.repo.GetJob(ctx, id)
+}
+
+func (r *Runner) Get(ctx context.Context, id int64) (*dto.Job, error) {
+	return r.repo.GetJob(ctx, id)
+}
+
+func (r *Runner) work(ctx context.Context) {
+	defer r.wg.Done()
+	
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	
+	for {
+		task, err := r.queue.Claim(ctx, KindGeneratePdf)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("ошибка получения задачи из очереди", zap.Error(err))
+		}
+		if task != nil {
+			r.run(ctx, task)
+			// Сразу пробуем взять следующую задачу, пока очередь не опустеет
+			continue
+		}
+	
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, task *queue.Task) {
+	logger := r.logger.With(zap.Int64("task_id", task.ID), zap.Int("attempt", task.Attempts))
+	
+	var p payload
+	if err := json.Unmarshal(task.Payload, &p); err != nil {
+		logger.Error("ошибка разбора задачи", zap.Error(err))
+		r.dead(ctx, task, nil, err, logger)
+		return
+	}
+	logger = logger.With(zap.Int64("job_id", p.JobID))
+	
+	job, err := r.repo.GetJob(ctx, p.JobID)
+	if errors.Is(err, repository.ErrNotFound) {
+		r.dead(ctx, task, nil, err, logger)
+		return
+	}
+	if err != nil {
+		logger.Error("ошибка загрузки задачи", zap.Error(err))
+		r.retry(ctx, task, job, err, logger)
+		return
+	}
+	if job.Status == dto.JobSucceeded || job.Status == dto.JobFailed {
+		r.complete(ctx, task, logger)
+		return
+	}
+	
+	if task.Exhausted {
+		// Воркер упал или завис на последней попытке: задача не выполняется, а сразу помечается failed
+		logger.Error("попытки исчерпаны, задача перемещена в dead")
+		r.dead(ctx, task, job, queue.ErrLeaseExpired, logger)
+		return
+	}
+	
+	if err := r.repo.MarkJobRunning(ctx, p.JobID); err != nil {
+		logger.Error("ошибка обновления задачи", zap.Error(err))
+		r.retry(ctx, task, job, err, logger)
+		return
+	}
+	
+	res, err := r.generateWithHeartbeat(ctx, task, job, logger)
+	if ctx.Err() != nil {
+		// Сервис останавливается: задачу подхватит другая реплика после таймаута видимости
+		logger.Warn("генерация прервана остановкой сервиса")
+		return
+	}
+	if errors.Is(err, queue.ErrLeaseLost) {
+		logger.Warn("задача перехвачена другим воркером, результат отброшен")
+		return
+	}
+	if errors.Is(err, pdfgen.ErrValidation) {
+		// Повтор невалидного запроса даст ту же ошибку
+		logger.Warn("невалидный запрос задачи", zap.Error(err))
+		r.dead(ctx, task, job, err, logger)
+		return
+	}
+	if err != nil {
+		logger.Error("ошибка генерации PDF", zap.Error(err))
+		r.retry(ctx, task, job, err, logger)
+		return
+	}
+	
+	if err := r.repo.MarkJobSucceeded(ctx, p.JobID, res.Key, res.Size, res.SHA256); err != nil {
+		logger.Error("ошибка обновления задачи", zap.Error(err))
+		r.retry(ctx, task, job, err, logger)
+		return
+	}
+	r.complete(ctx, task, logger)
+	r.notify(ctx, job, res, nil, logger)
+	logger.Info("PDF сгенерирован", zap.String("key", res.Key))
+}
+
+// generateWithHeartbeat генерирует PDF, периодически продлевая таймаут видимости задачи.
+// Если задачу перехватил другой воркер, генерация прерывается с queue.ErrLeaseLost.
+func (r *Runner) generateWithHeartbeat(ctx context.Context, task *queue.Task, job *dto.Job, logger *zap.Logger) (*pdfgen.Result, error) {
+	genCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(r.queue.VisibilityTimeout() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-genCtx.Done():
+				return
+			case <-ticker.C:
+				err := r.queue.Extend(genCtx, task)
+				if errors.Is(err, queue.ErrLeaseLost) {
+					cancel(err)
+					return
+				}
+				if err != nil && genCtx.Err() == nil {
+					logger.Warn("ошибка продления задачи", zap.Error(err))
+				}
+			}
+		}
+	}()
+	
+	res, err := r.generate(genCtx, job)
+	if cause := context.Cause(genCtx); errors.Is(cause, queue.ErrLeaseLost) {
+		return nil, cause
+	}
+	return res, err
+}
+
+func (r *Runner) generate(ctx context.Context, job *dto.Job) (*pdfgen.Result, error) {
+	var req dto.SaveRequest
+	if err := json.Unmarshal(job.Request, &req); err != nil {
+		return nil, fmt.Errorf("%w: ошибка разбора запроса задачи: %v", pdfgen.ErrValidation, err)
+	}
+	
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	
+	return r.pdfGen.GenerateAdvancedPDFWithGofpdf(ctx, req)
+}
+
+func (r *Runner) complete(ctx context.Context, task *queue.Task, logger *zap.Logger) {
+	if err := r.queue.Complete(context.WithoutCancel(ctx), task); err != nil {
+		logger.Error("ошибка завершения задачи в очереди", zap.Error(err))
+	}
+}
+
+// retry возвращает задачу в очередь с задержкой, после последней попытки задача помечается failed
+func (r *Runner) retry(ctx context.Context, task *queue.Task, job *dto.Job, cause error, logger *zap.Logger) {
+	ctx = context.WithoutCancel(ctx)
+	
+	retried, err := r.queue.Retry(ctx, task, cause)
+	if err != nil {
+		logger.Error("ошибка возврата задачи в очередь", zap.Error(err))
+		return
+	}
+	if !retried {
+		logger.Error("попытки исчерпаны, задача перемещена в dead")
+		r.markFailed(ctx, job, cause, logger)
+		return
+	}
+	if job == nil {
+		return
+	}
+	if err := r.repo.MarkJobQueued(ctx, job.ID, cause.Error()); err != nil {
+		logger.Error("ошибка обновления задачи", zap.Error(err))
+	}
+}
+
+func (r *Runner) dead(ctx context.Context, task *queue.Task, job *dto.Job, cause error, logger *zap.Logger) {
+	ctx = context.WithoutCancel(ctx)
+	
+	if err := r.queue.Dead(ctx, task, cause); err != nil {
+		logger.Error("ошибка перемещения задачи в dead", zap.Error(err))
+		return
+	}
+	r.markFailed(ctx, job, cause, logger)
+}
+
+func (r *Runner) markFailed(ctx context.Context, job *dto.Job, cause error, logger *zap.Logger) {
+	if job == nil {
+		return
+	}
+	if err := r.repo.MarkJobFailed(ctx, job.ID, cause.Error()); err != nil {
+		logger.Error("ошибка обновления задачи", zap.Error(err))
+		return
+	}
+	r.notify(ctx, job, nil, cause, logger)
+}
+
+// notify ставит уведомление о завершении задачи, ошибка постановки только логируется
+func (r *Runner) notify(ctx context.Context, job *dto.Job, res *pdfgen.Result, cause error, logger *zap.Logger) {
+	// Запрос мог не разобраться, тогда уведомление уходит на адрес по умолчанию
+	var req dto.SaveRequest
+	_ = json.Unmarshal(job.Request, &req)
+	
+	payload := dto.WebhookPayload{
+		Event:         dto.WebhookPdfSucceeded,
+		JobID:         job.ID,
+		CartId:        req.CartId,
+		PublicationId: req.PublicationId,
+	}
+	if res != nil {
+		payload.Key = res.Key
+		payload.URL = res.URL
+		payload.Size = res.Size
+		payload.SHA256 = res.SHA256
+		payload.InputHash = res.InputHash
+	}
+	if cause != nil {
+		payload.Event = dto.WebhookPdfFailed
+		payload.Error = cause.Error()
+	}
+	
+	if err := r.notifier.Notify(context.WithoutCancel(ctx), req.CallbackURL, payload); err != nil {
+		logger.Error("ошибка постановки уведомления", zap.Error(err))
+	}
+}
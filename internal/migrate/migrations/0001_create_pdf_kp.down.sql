@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pdf_kp;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS pdf_kp(
+	id BIGSERIAL PRIMARY KEY,
+	id_user BIGINT,
+	id_cart BIGINT,
+	id_publication BIGINT,
+	publication_url TEXT,
+	logo JSONB,
+	executor_parameters JSONB,
+	presentation_parameters JSONB,
+	style_template JSONB,
+	count INT,
+	save_required BOOLEAN,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP
+);
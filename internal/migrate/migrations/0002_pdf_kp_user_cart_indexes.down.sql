@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS pdf_kp_id_cart_idx;
+DROP INDEX IF EXISTS pdf_kp_id_user_idx;
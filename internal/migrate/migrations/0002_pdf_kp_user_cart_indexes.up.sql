@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS pdf_kp_id_user_idx ON pdf_kp (id_user, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS pdf_kp_id_cart_idx ON pdf_kp (id_cart, created_at DESC, id DESC);
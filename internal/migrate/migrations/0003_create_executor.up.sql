@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS executor(
+	id_user BIGINT PRIMARY KEY,
+	company_name TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL DEFAULT '',
+	logo TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP
+);
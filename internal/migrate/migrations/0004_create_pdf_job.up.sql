@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS pdf_job(
+	id BIGSERIAL PRIMARY KEY,
+	status TEXT NOT NULL,
+	request JSONB NOT NULL,
+	result_key TEXT NOT NULL DEFAULT '',
+	result_size BIGINT NOT NULL DEFAULT 0,
+	result_sha256 TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	started_at TIMESTAMP,
+	finished_at TIMESTAMP
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS queue_task(
+	id BIGSERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL,
+	run_at TIMESTAMP NOT NULL,
+	locked_by TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMP,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS queue_task_claim_idx ON queue_task (kind, status, run_at);
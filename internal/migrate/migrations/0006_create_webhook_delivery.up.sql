@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS webhook_delivery(
+	id BIGSERIAL PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	job_id BIGINT,
+	event TEXT NOT NULL,
+	url TEXT NOT NULL,
+	attempt INT NOT NULL,
+	status_code INT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	duration_ms BIGINT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
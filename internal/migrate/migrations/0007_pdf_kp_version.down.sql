@@ -0,0 +1,2 @@
+ALTER TABLE pdf_kp DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE pdf_kp DROP COLUMN IF EXISTS version;
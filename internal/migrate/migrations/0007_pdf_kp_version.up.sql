@@ -0,0 +1,2 @@
+ALTER TABLE pdf_kp ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE pdf_kp ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS pdf_kp_revision(
+	id BIGSERIAL PRIMARY KEY,
+	pdf_kp_id BIGINT NOT NULL REFERENCES pdf_kp (id),
+	number INT NOT NULL,
+	key TEXT NOT NULL,
+	sha256 TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	pages INT NOT NULL,
+	template_id TEXT NOT NULL,
+	input JSONB NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (pdf_kp_id, number)
+);
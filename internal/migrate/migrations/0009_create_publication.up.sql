@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS publication(
+	id BIGSERIAL PRIMARY KEY,
+	pdf_kp_id BIGINT NOT NULL REFERENCES pdf_kp (id),
+	slug TEXT NOT NULL UNIQUE,
+	expires_at TIMESTAMP,
+	revoked BOOLEAN NOT NULL DEFAULT false,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS publication_pdf_kp_id_idx ON publication (pdf_kp_id);
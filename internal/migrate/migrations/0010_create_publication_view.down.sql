@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS publication_view;
+ALTER TABLE publication DROP COLUMN IF EXISTS first_viewed_at;
@@ -0,0 +1,13 @@
+ALTER TABLE publication ADD COLUMN IF NOT EXISTS first_viewed_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS publication_view(
+	id BIGSERIAL PRIMARY KEY,
+	publication_id BIGINT NOT NULL REFERENCES publication (id),
+	pdf_kp_id BIGINT NOT NULL,
+	ip_hash TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	referer TEXT NOT NULL DEFAULT '',
+	viewed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS publication_view_pdf_kp_id_idx ON publication_view (pdf_kp_id, viewed_at);
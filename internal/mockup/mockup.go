@@ -0,0 +1,249 @@
+package mockup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+var ErrInvalidArea = errors.New("невалидная область нанесения")
+
+// Composite наносит логотип на изображение товара в области нанесения и возвращает PNG.
+// Логотип вписывается в прямоугольник области с сохранением пропорций, затем
+// поворачивается на Rotation или проецируется на четырехугольник Quad.
+func Composite(product, logo image.Image, area dto.PrintArea) ([]byte, error) {
+	dst := image.NewRGBA(product.Bounds())
+	draw.Draw(dst, dst.Bounds(), product, product.Bounds().Min, draw.Src)
+
+	if err := Overlay(dst, logo, area); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("ошибка кодирования мокапа: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Overlay рисует логотип поверх dst в области нанесения
+func Overlay(dst *image.RGBA, logo image.Image, area dto.PrintArea) error {
+	if area.Width <= 0 || area.Height <= 0 {
+		return fmt.Errorf("%w: нулевой размер", ErrInvalidArea)
+	}
+	lb := logo.Bounds()
+	if lb.Dx() == 0 || lb.Dy() == 0 {
+		return fmt.Errorf("пустой логотип")
+	}
+
+	// Вписываем логотип в область: (u, v) - координаты внутри области до поворота/перспективы
+	scale := math.Min(area.Width/float64(lb.Dx()), area.Height/float64(lb.Dy()))
+	offsetX := (area.Width - float64(lb.Dx())*scale) / 2
+	offsetY := (area.Height - float64(lb.Dy())*scale) / 2
+
+	quad, err := destinationQuad(area)
+	if err != nil {
+		return err
+	}
+	h, err := homography([4]point{{0, 0}, {area.Width, 0}, {area.Width, area.Height}, {0, area.Height}}, quad)
+	if err != nil {
+		return err
+	}
+	inv, err := h.inverse()
+	if err != nil {
+		return err
+	}
+
+	bounds := quadBounds(quad).Intersect(dst.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			u, v, ok := inv.apply(float64(x)+0.5, float64(y)+0.5)
+			if !ok {
+				continue
+			}
+			lx := (u-offsetX)/scale + float64(lb.Min.X)
+			ly := (v-offsetY)/scale + float64(lb.Min.Y)
+			src, ok := bilinear(logo, lx, ly)
+			if !ok || src.A == 0 {
+				continue
+			}
+			dst.SetRGBA(x, y, blend(dst.RGBAAt(x, y), src))
+		}
+	}
+
+	return nil
+}
+
+type point struct {
+	x, y float64
+}
+
+// destinationQuad углы области на изображении товара: по часовой стрелке от левого верхнего
+func destinationQuad(area dto.PrintArea) ([4]point, error) {
+	if len(area.Quad) > 0 {
+		if len(area.Quad) != 4 {
+			return [4]point{}, fmt.Errorf("%w: quad должен содержать 4 точки", ErrInvalidArea)
+		}
+		var quad [4]point
+		for i, p := range area.Quad {
+			quad[i] = point{p.X, p.Y}
+		}
+		return quad, nil
+	}
+
+	cx, cy := area.X+area.Width/2, area.Y+area.Height/2
+	sin, cos := math.Sincos(area.Rotation * math.Pi / 180)
+	corners := [4]point{
+		{-area.Width / 2, -area.Height / 2},
+		{area.Width / 2, -area.Height / 2},
+		{area.Width / 2, area.Height / 2},
+		{-area.Width / 2, area.Height / 2},
+	}
+	var quad [4]point
+	for i, c := range corners {
+		quad[i] = point{cx + c.x*cos - c.y*sin, cy + c.x*sin + c.y*cos}
+	}
+	return quad, nil
+}
+
+func quadBounds(quad [4]point) image.Rectangle {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range quad {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+// matrix проективное преобразование 3x3
+type matrix [9]float64
+
+func (m matrix) apply(x, y float64) (float64, float64, bool) {
+	w := m[6]*x + m[7]*y + m[8]
+	if math.Abs(w) < 1e-12 {
+		return 0, 0, false
+	}
+	return (m[0]*x + m[1]*y + m[2]) / w, (m[3]*x + m[4]*y + m[5]) / w, true
+}
+
+func (m matrix) inverse() (matrix, error) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+	if math.Abs(det) < 1e-12 {
+		return matrix{}, fmt.Errorf("%w: вырожденная область", ErrInvalidArea)
+	}
+	return matrix{
+		(m[4]*m[8] - m[5]*m[7]) / det,
+		(m[2]*m[7] - m[1]*m[8]) / det,
+		(m[1]*m[5] - m[2]*m[4]) / det,
+		(m[5]*m[6] - m[3]*m[8]) / det,
+		(m[0]*m[8] - m[2]*m[6]) / det,
+		(m[2]*m[3] - m[0]*m[5]) / det,
+		(m[3]*m[7] - m[4]*m[6]) / det,
+		(m[1]*m[6] - m[0]*m[7]) / det,
+		(m[0]*m[4] - m[1]*m[3]) / det,
+	}, nil
+}
+
+// homography находит преобразование, переводящее 4 точки src в 4 точки dst
+func homography(src, dst [4]point) (matrix, error) {
+	// Система 8x8 относительно h0..h7 при h8 = 1
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := src[i].x, src[i].y
+		u, v := dst[i].x, dst[i].y
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// Метод Гаусса с выбором главного элемента
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return matrix{}, fmt.Errorf("%w: вырожденная область", ErrInvalidArea)
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var m matrix
+	for i := 0; i < 8; i++ {
+		m[i] = a[i][8] / a[i][i]
+	}
+	m[8] = 1
+	return m, nil
+}
+
+// bilinear берет цвет логотипа в дробной точке с билинейной интерполяцией
+func bilinear(img image.Image, x, y float64) (color.RGBA, bool) {
+	b := img.Bounds()
+	x -= 0.5
+	y -= 0.5
+	if x < float64(b.Min.X)-0.5 || y < float64(b.Min.Y)-0.5 || x > float64(b.Max.X)-0.5 || y > float64(b.Max.Y)-0.5 {
+		return color.RGBA{}, false
+	}
+
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	var r, g, bl, a float64
+	for _, s := range [4]struct {
+		dx, dy int
+		w      float64
+	}{
+		{0, 0, (1 - fx) * (1 - fy)},
+		{1, 0, fx * (1 - fy)},
+		{0, 1, (1 - fx) * fy},
+		{1, 1, fx * fy},
+	} {
+		px := clamp(x0+s.dx, b.Min.X, b.Max.X-1)
+		py := clamp(y0+s.dy, b.Min.Y, b.Max.Y-1)
+		c := color.RGBAModel.Convert(img.At(px, py)).(color.RGBA)
+		r += float64(c.R) * s.w
+		g += float64(c.G) * s.w
+		bl += float64(c.B) * s.w
+		a += float64(c.A) * s.w
+	}
+
+	return color.RGBA{R: uint8(r + 0.5), G: uint8(g + 0.5), B: uint8(bl + 0.5), A: uint8(a + 0.5)}, true
+}
+
+// blend накладывает src (premultiplied alpha) поверх dst
+func blend(dst, src color.RGBA) color.RGBA {
+	k := 255 - uint32(src.A)
+	return color.RGBA{
+		R: uint8(uint32(src.R) + uint32(dst.R)*k/255),
+		G: uint8(uint32(src.G) + uint32(dst.G)*k/255),
+		B: uint8(uint32(src.B) + uint32(dst.B)*k/255),
+		A: uint8(uint32(src.A) + uint32(dst.A)*k/255),
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
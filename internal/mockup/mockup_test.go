@@ -0,0 +1,171 @@
+package mockup
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "перезаписать эталонные мокапы в testdata")
+
+// Фикстуры: product.png 160x120 с градиентом, logo.png 40x40 с прозрачной рамкой 4px
+// и четвертями красный, зеленый (сверху), синий, белый (снизу)
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+// area область 80x80 в (40, 20): логотип масштабируется в 2 раза, непрозрачная часть - 48..112 x 28..92
+var area = dto.PrintArea{X: 40, Y: 20, Width: 80, Height: 80}
+
+func TestComposite(t *testing.T) {
+	rotated := area
+	rotated.Rotation = 90
+	perspective := area
+	perspective.Quad = []dto.Point{{X: 40, Y: 20}, {X: 120, Y: 35}, {X: 120, Y: 85}, {X: 40, Y: 100}}
+
+	tests := []struct {
+		name string
+		area dto.PrintArea
+		// probes точки внутри четвертей логотипа: левая верхняя, правая верхняя, левая нижняя, правая нижняя
+		probes [4]color.RGBA
+	}{
+		{"flat", area, [4]color.RGBA{red, green, blue, white}},
+		// Поворот по часовой стрелке: левая верхняя четверть уходит вправо вверх
+		{"rotated", rotated, [4]color.RGBA{blue, red, white, green}},
+		{"perspective", perspective, [4]color.RGBA{red, green, blue, white}},
+	}
+	product, logo := fixture(t, "product.png"), fixture(t, "logo.png")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Composite(product, logo, tt.area)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Bounds() != product.Bounds() {
+				t.Fatalf("размер мокапа %v, want %v", got.Bounds(), product.Bounds())
+			}
+
+			for i, p := range []image.Point{{60, 45}, {100, 45}, {60, 75}, {100, 75}} {
+				assertColor(t, got, p, tt.probes[i])
+			}
+			// Прозрачная рамка логотипа и все вне области не меняются
+			for _, p := range []image.Point{{42, 22}, {10, 10}, {150, 110}} {
+				assertColor(t, got, p, color.RGBAModel.Convert(product.At(p.X, p.Y)).(color.RGBA))
+			}
+
+			golden := filepath.Join("testdata", "composite_"+tt.name+".png")
+			if *update {
+				if err := os.WriteFile(golden, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertSimilar(t, got, fixture(t, filepath.Base(golden)))
+		})
+	}
+}
+
+func TestOverlayInvalidArea(t *testing.T) {
+	tests := []struct {
+		name string
+		area dto.PrintArea
+	}{
+		{"zero size", dto.PrintArea{X: 10, Y: 10}},
+		{"three points", dto.PrintArea{Width: 10, Height: 10, Quad: []dto.Point{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}}},
+		{"collinear", dto.PrintArea{Width: 10, Height: 10, Quad: []dto.Point{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 20, Y: 0}, {X: 30, Y: 0}}}},
+	}
+	logo := fixture(t, "logo.png")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := image.NewRGBA(image.Rect(0, 0, 50, 50))
+			if err := Overlay(dst, logo, tt.area); !errors.Is(err, ErrInvalidArea) {
+				t.Errorf("Overlay() error = %v, want ErrInvalidArea", err)
+			}
+		})
+	}
+}
+
+func TestHomography(t *testing.T) {
+	src := [4]point{{0, 0}, {80, 0}, {80, 80}, {0, 80}}
+	dst := [4]point{{40, 20}, {120, 35}, {118, 85}, {37, 100}}
+	h, err := homography(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := h.inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range src {
+		x, y, ok := h.apply(src[i].x, src[i].y)
+		if !ok || math.Abs(x-dst[i].x) > 1e-6 || math.Abs(y-dst[i].y) > 1e-6 {
+			t.Errorf("угол %d: (%v, %v), want %v", i, x, y, dst[i])
+		}
+		u, v, ok := inv.apply(dst[i].x, dst[i].y)
+		if !ok || math.Abs(u-src[i].x) > 1e-6 || math.Abs(v-src[i].y) > 1e-6 {
+			t.Errorf("обратное для угла %d: (%v, %v), want %v", i, u, v, src[i])
+		}
+	}
+}
+
+func fixture(t *testing.T, name string) image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func assertColor(t *testing.T, img image.Image, p image.Point, want color.RGBA) {
+	t.Helper()
+	if got := color.RGBAModel.Convert(img.At(p.X, p.Y)).(color.RGBA); got != want {
+		t.Errorf("пиксель %v = %v, want %v", p, got, want)
+	}
+}
+
+// assertSimilar сравнивает с эталоном с допуском в пару единиц на канал:
+// на части архитектур компилятор объединяет умножение со сложением, и округление отличается
+func assertSimilar(t *testing.T, got, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("размер %v, эталон %v", got.Bounds(), want.Bounds())
+	}
+	const tolerance = 2
+	b := got.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+			w := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			if diff(g.R, w.R) > tolerance || diff(g.G, w.G) > tolerance || diff(g.B, w.B) > tolerance || diff(g.A, w.A) > tolerance {
+				t.Fatalf("пиксель (%d, %d) = %v, эталон %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
@@ -0,0 +1,13 @@
+package pdfgen
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrValidation ошибка во входных данных запроса, клиенту отдается как 400
+var ErrValidation = errors.New("невалидные данные")
+
+func validationErrorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrValidation, fmt.Sprintf(format, args...))
+}
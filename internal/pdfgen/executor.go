@@ -0,0 +1,91 @@
+package pdfgen
+
+import (
+	"context"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ExecutorSource источник профиля исполнителя по id_user. Если профиля нет, возвращает nil, nil
+type ExecutorSource interface {
+	FindExecutor(ctx context.Context, userId int64) (*dto.Executor, error)
+}
+
+const (
+	headerHeight = 18.0
+	footerHeight = 15.0
+)
+
+// executorParamForPage выбирает блок параметров для страницы: First для первой,
+// Last для последней, All для остальных. totalPages == 0 - число страниц еще неизвестно.
+func executorParamForPage(params dto.ExecutorParameters, page, totalPages int) dto.ExecutorParam {
+	switch {
+	case page == 1:
+		return params.First
+	case totalPages > 0 && page == totalPages:
+		return params.Last
+	default:
+		return params.All
+	}
+}
+
+// setupExecutorHeaderFooter вешает на документ колонтитулы с данными исполнителя
+func setupExecutorHeaderFooter(pdf *gofpdf.Fpdf, t Theme, executor *dto.Executor, params dto.ExecutorParameters, images imageSet, totalPages int) {
+	left, top, right, bottom := pdf.GetMargins()
+	pdf.SetTopMargin(math.Max(top, headerHeight+7))
+	pdf.SetAutoPageBreak(true, math.Max(bottom, footerHeight+5))
+
+	pdf.SetHeaderFunc(func() {
+		param := executorParamForPage(params, pdf.PageNo(), totalPages)
+		pageWidth, _ := pdf.GetPageSize()
+
+		if asset := images[executor.Logo]; param.ShowLogo && asset != nil {
+			placeImage(pdf, asset, left, 8, 40, 10, "L")
+		}
+		if isShown(param.ShowName) && executor.CompanyName != "" {
+			pdf.SetFont(t.Fonts.Heading, "B", 10)
+			if t.HeaderFooter.AccentName {
+				t.setTextColor(pdf, t.Palette.Accent)
+			}
+			pdf.SetXY(left, 8)
+			pdf.CellFormat(pageWidth-left-right, 10, executor.CompanyName, "", 0, "R", false, 0, "")
+		}
+		if t.HeaderFooter.Rule && (param.ShowLogo || isShown(param.ShowName)) {
+			pdf.SetDrawColor(t.Table.Border.R, t.Table.Border.G, t.Table.Border.B)
+			pdf.Line(left, headerHeight+2, pageWidth-right, headerHeight+2)
+		}
+	})
+
+	pdf.SetFooterFunc(func() {
+		param := executorParamForPage(params, pdf.PageNo(), totalPages)
+		pageWidth, _ := pdf.GetPageSize()
+
+		pdf.SetY(-footerHeight)
+		pdf.SetFont(t.Fonts.Body, "", 8)
+		t.setTextColor(pdf, t.Palette.Muted)
+		if isShown(param.ShowContacts) {
+			var contacts []string
+			for _, v := range []string{executor.Phone, executor.Email} {
+				if v != "" {
+					contacts = append(contacts, v)
+				}
+			}
+			pdf.CellFormat(pageWidth-left-right, 5, strings.Join(contacts, " · "), "", 0, "L", false, 0, "")
+			pdf.SetX(left)
+		}
+		pdf.CellFormat(pageWidth-left-right, 5, "стр. "+strconv.Itoa(pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+}
+
+// isShown трактует строковые флаги show_name/show_contacts: пусто, "false", "0", "no" - не показывать
+func isShown(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "false", "0", "no", "нет":
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,190 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultImageMaxSize   = 10 << 20
+	defaultImageMaxPixels = 50_000_000
+	defaultImageTimeout   = 10 * time.Second
+)
+
+// ImageFetcher загружает картинки, переданные ссылкой: s3://bucket/key, ключом
+// в хранилище под KeyPrefix или http(s) URL с хоста из списка разрешенных
+type ImageFetcher struct {
+	store        storage.Store
+	bucket       string
+	keyPrefix    string
+	allowedHosts map[string]bool
+	maxSize      int64
+	maxPixels    int64
+	timeout      time.Duration
+	httpClient   *http.Client
+}
+
+func NewImageFetcher(store storage.Store, bucket string, cfg config.ImagesConfig) *ImageFetcher {
+	f := &ImageFetcher{
+		store:        store,
+		bucket:       bucket,
+		keyPrefix:    strings.Trim(cfg.KeyPrefix, "/"),
+		allowedHosts: make(map[string]bool),
+		maxSize:      cfg.MaxSize,
+		maxPixels:    cfg.MaxPixels,
+		timeout:      cfg.Timeout,
+	}
+	if f.maxSize <= 0 {
+		f.maxSize = defaultImageMaxSize
+	}
+	if f.maxPixels <= 0 {
+		f.maxPixels = defaultImageMaxPixels
+	}
+	if f.timeout <= 0 {
+		f.timeout = defaultImageTimeout
+	}
+	for _, host := range cfg.AllowedHosts {
+		f.allowedHosts[strings.ToLower(host)] = true
+	}
+
+	f.httpClient = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("слишком много редиректов")
+			}
+			if !f.allowedHosts[strings.ToLower(req.URL.Host)] {
+				return fmt.Errorf("редирект на запрещенный хост %s", req.URL.Host)
+			}
+			return nil
+		},
+	}
+
+	return f
+}
+
+// IsReference отличает ссылку на картинку от base64
+func (f *ImageFetcher) IsReference(src string) bool {
+	switch {
+	case strings.HasPrefix(src, "s3://"), strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+		return true
+	case f.keyPrefix != "":
+		return strings.HasPrefix(src, f.keyPrefix+"/")
+	default:
+		return false
+	}
+}
+
+// Fetch скачивает картинку по ссылке, ошибки в самой ссылке возвращаются как ErrValidation
+func (f *ImageFetcher) Fetch(ctx context.Context, src string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return f.fetchURL(ctx, src)
+	}
+
+	key := src
+	if strings.HasPrefix(src, "s3://") {
+		bucket, objectKey, _ := strings.Cut(strings.TrimPrefix(src, "s3://"), "/")
+		if bucket != f.bucket {
+			return nil, validationErrorf("бакет %q недоступен", bucket)
+		}
+		key = objectKey
+	}
+	key, err := f.allowedKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.fetchObject(ctx, key)
+}
+
+// allowedKey нормализует ключ и пропускает только ключи под KeyPrefix: остальной бакет,
+// в том числе чужие КП в upload_dir, через картинки не читается. Без KeyPrefix объекты недоступны
+func (f *ImageFetcher) allowedKey(key string) (string, error) {
+	if key == "" {
+		return "", validationErrorf("пустой ключ объекта")
+	}
+	cleaned, err := storage.CleanKey(key)
+	if err != nil {
+		return "", validationErrorf("невалидный ключ объекта %q", key)
+	}
+	if f.keyPrefix == "" || !strings.HasPrefix(cleaned, f.keyPrefix+"/") {
+		return "", validationErrorf("объект %q недоступен", key)
+	}
+	return cleaned, nil
+}
+
+func (f *ImageFetcher) fetchObject(ctx context.Context, key string) ([]byte, error) {
+	body, obj, err := f.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		return nil, validationErrorf("объект %q не найден", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки %q из хранилища: %w", key, err)
+	}
+	defer body.Close()
+
+	if obj.Size > f.maxSize {
+		return nil, validationErrorf("объект %q больше %d байт", key, f.maxSize)
+	}
+
+	return f.readLimited(body, key)
+}
+
+func (f *ImageFetcher) fetchURL(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, validationErrorf("невалидный URL %q", rawURL)
+	}
+	if !f.allowedHosts[strings.ToLower(u.Host)] {
+		return nil, validationErrorf("хост %q не входит в список разрешенных", u.Host)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, validationErrorf("невалидный URL %q", rawURL)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки %q: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, validationErrorf("%q вернул статус %d", rawURL, resp.StatusCode)
+	}
+	if resp.ContentLength > f.maxSize {
+		return nil, validationErrorf("%q больше %d байт", rawURL, f.maxSize)
+	}
+
+	return f.readLimited(resp.Body, rawURL)
+}
+
+func (f *ImageFetcher) readLimited(r io.Reader, src string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %q: %w", src, err)
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, validationErrorf("%q больше %d байт", src, f.maxSize)
+	}
+	return data, nil
+}
+
+// pixelLimit ограничение размера изображений, без загрузчика - по умолчанию
+func (f *ImageFetcher) pixelLimit() int64 {
+	if f == nil {
+		return defaultImageMaxPixels
+	}
+	return f.maxPixels
+}
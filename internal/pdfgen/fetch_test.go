@@ -0,0 +1,133 @@
+package pdfgen
+
+import (
+	"context"
+	"errors"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestFetcher(t *testing.T, allowedHosts ...string) *ImageFetcher {
+	t.Helper()
+	store := storage.NewMemory()
+	objects := map[string]string{
+		"uploads/logo.png":      "logo",
+		"uploads/big.png":       strings.Repeat("x", 100),
+		"pdfs/0123abcd.pdf":     "чужой КП",
+		"uploads-private/a.png": "соседний префикс",
+	}
+	for key, body := range objects {
+		if err := store.Put(context.Background(), key, strings.NewReader(body), storage.PutOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewImageFetcher(store, "bucket", config.ImagesConfig{
+		KeyPrefix:    "/uploads/",
+		AllowedHosts: allowedHosts,
+		MaxSize:      50,
+	})
+}
+
+func TestFetchObject(t *testing.T) {
+	f := newTestFetcher(t)
+	tests := []struct {
+		src     string
+		want    string
+		invalid bool
+	}{
+		{src: "uploads/logo.png", want: "logo"},
+		{src: "s3://bucket/uploads/logo.png", want: "logo"},
+		{src: "uploads/./logo.png", want: "logo"},
+		{src: "s3://other/uploads/logo.png", invalid: true},
+		{src: "s3://bucket/pdfs/0123abcd.pdf", invalid: true},
+		{src: "s3://bucket/uploads-private/a.png", invalid: true},
+		{src: "uploads/../pdfs/0123abcd.pdf", invalid: true},
+		{src: "s3://bucket/uploads/../pdfs/0123abcd.pdf", invalid: true},
+		{src: "s3://bucket/uploads/../../etc/passwd", invalid: true},
+		{src: "s3://bucket/", invalid: true},
+		{src: "uploads/missing.png", invalid: true},
+		{src: "uploads/big.png", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if !f.IsReference(tt.src) {
+				t.Fatalf("IsReference(%q) = false", tt.src)
+			}
+			data, err := f.Fetch(context.Background(), tt.src)
+			if tt.invalid {
+				if !errors.Is(err, ErrValidation) {
+					t.Fatalf("Fetch() = %q, %v, want ErrValidation", data, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Fetch() = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	f := newTestFetcher(t)
+	for src, want := range map[string]bool{
+		"uploads/logo.png":                   true,
+		"s3://bucket/a.png":                  true,
+		"https://cdn.example.com/a.png":      true,
+		"iVBORw0KGgoAAAANSUhEUgAAAAEAAAAB":   false,
+		"data:image/png;base64,iVBORw0KGgo=": false,
+		"uploadsx/logo.png":                  false,
+	} {
+		if got := f.IsReference(src); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("logo"))
+	})
+	mux.HandleFunc("/big.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://forbidden.example.com/logo.png", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	f := newTestFetcher(t, strings.ToUpper(host))
+
+	data, err := f.Fetch(context.Background(), srv.URL+"/logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "logo" {
+		t.Errorf("Fetch() = %q, want %q", data, "logo")
+	}
+
+	for _, src := range []string{
+		srv.URL + "/missing.png",
+		srv.URL + "/big.png",
+		"http://forbidden.example.com/logo.png",
+	} {
+		if _, err := f.Fetch(context.Background(), src); !errors.Is(err, ErrValidation) {
+			t.Errorf("Fetch(%q) error = %v, want ErrValidation", src, err)
+		}
+	}
+
+	_, err = f.Fetch(context.Background(), srv.URL+"/redirect")
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !strings.Contains(err.Error(), "forbidden.example.com") {
+		t.Errorf("редирект на запрещенный хост: error = %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package pdfgen
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"sort"
+	"strings"
+)
+
+// DefaultFontFamily семейство, которое используется, если шрифт не указан или неизвестен
+const DefaultFontFamily = "DejaVu"
+
+//go:embed fonts/*.ttf
+var fontFiles embed.FS
+
+// FontFamily набор начертаний одного семейства в формате TTF
+type FontFamily struct {
+	Name       string
+	Regular    []byte
+	Bold       []byte
+	Italic     []byte
+	BoldItalic []byte
+}
+
+// Style возвращает TTF для начертания в нотации gofpdf ("", "B", "I", "BI")
+func (f FontFamily) Style(style string) []byte {
+	switch style {
+	case "B":
+		return f.Bold
+	case "I":
+		return f.Italic
+	case "BI":
+		return f.BoldItalic
+	default:
+		return f.Regular
+	}
+}
+
+// FontRegistry хранит встроенные Unicode-шрифты и сопоставляет им имена из запроса (name_font)
+type FontRegistry struct {
+	families map[string]FontFamily
+	aliases  map[string]string
+	version  string
+}
+
+func NewFontRegistry() (*FontRegistry, error) {
+	r := &FontRegistry{
+		families: make(map[string]FontFamily),
+		aliases:  make(map[string]string),
+	}
+
+	dejavu, err := loadEmbeddedFamily(DefaultFontFamily, "DejaVuSansCondensed")
+	if err != nil {
+		return nil, err
+	}
+	r.Add(dejavu, "dejavu sans", "dejavu sans condensed", "arial", "helvetica")
+
+	r.Add(FontFamily{
+		Name:       "Go",
+		Regular:    goregular.TTF,
+		Bold:       gobold.TTF,
+		Italic:     goitalic.TTF,
+		BoldItalic: gobolditalic.TTF,
+	}, "go regular")
+
+	r.Add(FontFamily{
+		Name:       "GoMono",
+		Regular:    gomono.TTF,
+		Bold:       gomonobold.TTF,
+		Italic:     gomonoitalic.TTF,
+		BoldItalic: gomonobolditalic.TTF,
+	}, "go mono", "courier", "monospace")
+
+	return r, nil
+}
+
+// Add регистрирует семейство под его именем и дополнительными синонимами
+func (r *FontRegistry) Add(family FontFamily, aliases ...string) {
+	r.families[family.Name] = family
+	r.aliases[normalizeFontName(family.Name)] = family.Name
+	for _, alias := range aliases {
+		r.aliases[normalizeFontName(alias)] = family.Name
+	}
+	r.version = r.digest()
+}
+
+// Version версия набора шрифтов: меняется при добавлении семейства, синонима или замене TTF
+func (r *FontRegistry) Version() string {
+	return r.version
+}
+
+// digest sha256 по семействам в порядке имен, их TTF и таблице синонимов
+func (r *FontRegistry) digest() string {
+	h := sha256.New()
+	for _, name := range r.Names() {
+		family := r.families[name]
+		fmt.Fprintf(h, "family %s\n", name)
+		for _, style := range []string{"", "B", "I", "BI"} {
+			data := family.Style(style)
+			fmt.Fprintf(h, "style %q %d\n", style, len(data))
+			h.Write(data)
+		}
+	}
+	aliases := make([]string, 0, len(r.aliases))
+	for alias := range r.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		fmt.Fprintf(h, "alias %q %q\n", alias, r.aliases[alias])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Resolve возвращает имя зарегистрированного семейства для name_font, либо DefaultFontFamily
+func (r *FontRegistry) Resolve(name string) string {
+	if family, ok := r.aliases[normalizeFontName(name)]; ok {
+		return family
+	}
+	return DefaultFontFamily
+}
+
+// Family возвращает семейство по имени из запроса с тем же откатом, что и Resolve
+func (r *FontRegistry) Family(name string) FontFamily {
+	return r.families[r.Resolve(name)]
+}
+
+// Names возвращает отсортированный список зарегистрированных семейств
+func (r *FontRegistry) Names() []string {
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register подключает к документу семейство по умолчанию и семейства, нужные запросу, через AddUTF8Font
+func (r *FontRegistry) Register(pdf *gofpdf.Fpdf, names ...string) {
+	registered := make(map[string]bool)
+	for _, name := range append([]string{DefaultFontFamily}, names...) {
+		family := r.Family(name)
+		if registered[family.Name] {
+			continue
+		}
+		registered[family.Name] = true
+		for _, style := range []string{"", "B", "I", "BI"} {
+			pdf.AddUTF8FontFromBytes(family.Name, style, family.Style(style))
+		}
+	}
+	pdf.SetFont(DefaultFontFamily, "", 12)
+}
+
+func loadEmbeddedFamily(name, file string) (FontFamily, error) {
+	family := FontFamily{Name: name}
+	files := []struct {
+		dst  *[]byte
+		path string
+	}{
+		{&family.Regular, file + ".ttf"},
+		{&family.Bold, file + "-Bold.ttf"},
+		{&family.Italic, file + "-Oblique.ttf"},
+		{&family.BoldItalic, file + "-BoldOblique.ttf"},
+	}
+	for _, f := range files {
+		data, err := fontFiles.ReadFile("fonts/" + f.path)
+		if err != nil {
+			return FontFamily{}, fmt.Errorf("ошибка чтения шрифта %s: %w", f.path, err)
+		}
+		*f.dst = data
+	}
+	return family, nil
+}
+
+func normalizeFontName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer("-", " ", "_", " ").Replace(name)
+}
@@ -0,0 +1,60 @@
+package pdfgen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"time"
+)
+
+// renderVersion версия кода отрисовки. Увеличивается при любом изменении вывода,
+// чтобы КП, отрисованные старым кодом, не переиспользовались
+const renderVersion = 3
+
+// renderInput нормализованные входные данные КП. От них и только от них зависит результат отрисовки
+type renderInput struct {
+	Renderer        int             `json:"renderer"`
+	Template        string          `json:"template"`
+	TemplateVersion int             `json:"template_version"`
+	Fonts           string          `json:"fonts"`
+	Date            string          `json:"date"` // дата на титуле
+	Request         dto.SaveRequest `json:"request"`
+	Executor        *dto.Executor   `json:"executor"`
+	// Images sha256 содержимого картинок по sha256 исходной строки: картинка по ссылке
+	// могла измениться, а base64 слишком длинный, чтобы хранить его дважды
+	Images map[string]string `json:"images"`
+}
+
+// inputHash канонический хеш входных данных документа: sha256 от JSON renderInput.
+// encoding/json пишет поля структур в порядке объявления, а ключи map - отсортированными
+func (s *Page) inputHash(doc document) (string, error) {
+	req := doc.req
+	req.Cart = doc.cart
+	req.StyleTemplate.TemplateID = doc.theme.ID
+	req.Logo.LogoText.Font = doc.logoFont
+	// Адрес уведомления не влияет на документ
+	req.CallbackURL = ""
+
+	input := renderInput{
+		Renderer:        renderVersion,
+		Template:        doc.theme.ID,
+		TemplateVersion: doc.theme.Version,
+		Fonts:           s.fonts.Version(),
+		Date:            doc.date.Format(time.DateOnly),
+		Request:         req,
+		Executor:        doc.executor,
+		Images:          make(map[string]string, len(doc.images)),
+	}
+	for src, asset := range doc.images {
+		sum := sha256.Sum256([]byte(src))
+		input.Images[hex.EncodeToString(sum[:])] = asset.name
+	}
+
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(input); err != nil {
+		return "", fmt.Errorf("ошибка вычисления хеша КП: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,218 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/webp"
+	"image"
+	"image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// svgRasterSize размер большей стороны растра при конвертации SVG в PNG
+const svgRasterSize = 1024
+
+// imageAsset изображение, приведенное к JPEG или PNG и готовое к вставке в PDF
+type imageAsset struct {
+	name   string // sha256 содержимого, под ним изображение регистрируется в документе
+	typ    string // JPEG, PNG
+	data   []byte
+	width  int
+	height int
+}
+
+// imageSet подготовленные изображения документа по исходной строке из запроса
+type imageSet map[string]*imageAsset
+
+// add готовит изображение и запоминает его: ссылки скачиваются через fetcher,
+// остальное декодируется как base64. field нужен для текста ошибки
+func (s imageSet) add(ctx context.Context, fetcher *ImageFetcher, field, src string) error {
+	if src == "" {
+		return nil
+	}
+	if _, ok := s[src]; ok {
+		return nil
+	}
+
+	var (
+		asset *imageAsset
+		err   error
+	)
+	if fetcher != nil && fetcher.IsReference(src) {
+		data, err := fetcher.Fetch(ctx, src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		asset, err = prepareImage(data, fetcher.pixelLimit())
+	} else {
+		asset, err = decodeBase64Image(src, fetcher.pixelLimit())
+	}
+	if err != nil {
+		return validationErrorf("%s: %v", field, err)
+	}
+
+	s[src] = asset
+	return nil
+}
+
+// decodeBase64Image декодирует base64 (в том числе data URI) и приводит картинку к JPEG/PNG
+func decodeBase64Image(base64Data string, maxPixels int64) (*imageAsset, error) {
+	// Убираем префикс data:image/...;base64, если есть
+	if i := strings.Index(base64Data, ","); i >= 0 && strings.HasPrefix(base64Data, "data:") {
+		base64Data = base64Data[i+1:]
+	}
+	base64Data = strings.TrimSpace(base64Data)
+
+	data, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(base64Data)
+		if err != nil {
+			return nil, fmt.Errorf("невалидный base64")
+		}
+	}
+
+	return prepareImage(data, maxPixels)
+}
+
+// prepareImage определяет формат по содержимому: JPEG и PNG вставляются как есть,
+// GIF, WebP и SVG конвертируются в PNG. Размер растра сверяется с maxPixels
+// по заголовку, до декодирования
+func prepareImage(data []byte, maxPixels int64) (*imageAsset, error) {
+	var (
+		typ string
+		img image.Image
+		err error
+	)
+
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		typ = "JPEG"
+	case bytes.HasPrefix(data, []byte{0x89, 'P', 'N', 'G'}):
+		typ = "PNG"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		img, err = decodeLimited(data, maxPixels, gif.Decode)
+	case len(data) > 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		img, err = decodeLimited(data, maxPixels, webp.Decode)
+	case isSVG(data):
+		if img, err = rasterizeSVG(data); err != nil {
+			err = fmt.Errorf("ошибка декодирования изображения: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат изображения")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if img != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("ошибка конвертации в PNG: %v", err)
+		}
+		data, typ = buf.Bytes(), "PNG"
+	}
+
+	cfg, err := checkPixels(data, maxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	return &imageAsset{
+		name:   hex.EncodeToString(sum[:]),
+		typ:    typ,
+		data:   data,
+		width:  cfg.Width,
+		height: cfg.Height,
+	}, nil
+}
+
+// checkPixels читает размер из заголовка и отклоняет пустые и слишком большие изображения
+func checkPixels(data []byte, maxPixels int64) (image.Config, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, fmt.Errorf("ошибка декодирования изображения: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return cfg, fmt.Errorf("пустое изображение")
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return cfg, fmt.Errorf("изображение %dx%d больше %d пикселей", cfg.Width, cfg.Height, maxPixels)
+	}
+	return cfg, nil
+}
+
+// decodeLimited декодирует изображение, только если его размер в пределах maxPixels
+func decodeLimited(data []byte, maxPixels int64, decode func(io.Reader) (image.Image, error)) (image.Image, error) {
+	if _, err := checkPixels(data, maxPixels); err != nil {
+		return nil, err
+	}
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования изображения: %v", err)
+	}
+	return img, nil
+}
+
+func isSVG(data []byte) bool {
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	head = bytes.ToLower(bytes.TrimSpace(head))
+	return bytes.HasPrefix(head, []byte("<svg")) ||
+		(bytes.HasPrefix(head, []byte("<?xml")) && bytes.Contains(head, []byte("<svg")))
+}
+
+func rasterizeSVG(data []byte) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := icon.ViewBox.W, icon.ViewBox.H
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("у SVG не задан viewBox")
+	}
+	scale := svgRasterSize / math.Max(w, h)
+	width, height := int(math.Ceil(w*scale)), int(math.Ceil(h*scale))
+
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+
+	return img, nil
+}
+
+// placeImage вписывает изображение в прямоугольник с сохранением пропорций, по вертикали центрирует,
+// по горизонтали выравнивает по align ("L" или "C"), возвращает фактический размер на странице
+func placeImage(pdf *gofpdf.Fpdf, asset *imageAsset, x, y, boxW, boxH float64, align string) (float64, float64) {
+	ratio := float64(asset.width) / float64(asset.height)
+	w, h := boxW, boxW/ratio
+	if h > boxH {
+		w, h = boxH*ratio, boxH
+	}
+
+	options := gofpdf.ImageOptions{ImageType: asset.typ, ReadDpi: false}
+	if pdf.GetImageInfo(asset.name) == nil {
+		pdf.RegisterImageOptionsReader(asset.name, options, bytes.NewReader(asset.data))
+	}
+	if align != "L" {
+		x += (boxW - w) / 2
+	}
+	pdf.ImageOptions(asset.name, x, y+(boxH-h)/2, w, h, false, options, 0, "")
+
+	return w, h
+}
@@ -0,0 +1,153 @@
+package pdfgen
+
+import (
+	"github.com/jung-kurt/gofpdf"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"strconv"
+)
+
+// section часть документа, порядок секций в КП фиксирован
+type section int
+
+const (
+	sectionCover section = iota
+	sectionList
+	sectionOneByOne
+	sectionTotals
+)
+
+func (s section) String() string {
+	switch s {
+	case sectionCover:
+		return "cover"
+	case sectionList:
+		return "list"
+	case sectionOneByOne:
+		return "one_by_one"
+	case sectionTotals:
+		return "totals"
+	default:
+		return "unknown"
+	}
+}
+
+// planSections определяет состав и порядок секций по параметрам презентации:
+// обложка, затем таблица списком, затем страницы по одному товару, затем итоги.
+// Если не выбран ни один вид, КП выводится по одному товару на страницу.
+// Итоги отдельной секцией нужны только без списка - в таблице списка они уже есть.
+func planSections(params dto.PresentationParameters) []section {
+	sections := []section{sectionCover}
+	if params.List {
+		sections = append(sections, sectionList)
+	}
+	if params.OneByOne || !params.List {
+		sections = append(sections, sectionOneByOne)
+	}
+	if params.Sum && !params.List {
+		sections = append(sections, sectionTotals)
+	}
+	return sections
+}
+
+// renderList рисует компактную таблицу всех позиций корзины
+func renderList(pdf *gofpdf.Fpdf, t Theme, cart *dto.Cart, params dto.PresentationParameters) {
+	pdf.AddPage()
+
+	pdf.SetFont(t.Fonts.Heading, "B", 16)
+	t.setTextColor(pdf, t.Palette.Accent)
+	pdf.Cell(0, 10, "Состав предложения")
+	t.setTextColor(pdf, t.Palette.Text)
+	pdf.Ln(14)
+
+	cols := []listColumn{
+		{title: "№", width: 10, align: "C"},
+		{title: "Наименование", width: 80, align: "L"},
+		{title: "Артикул", width: 30, align: "L"},
+		{title: "Кол-во", width: 20, align: "R"},
+	}
+	if params.Price {
+		cols = append(cols, listColumn{title: "Цена", width: 25, align: "R"})
+	}
+	if params.Sum {
+		cols = append(cols, listColumn{title: "Сумма", width: 25, align: "R"})
+	}
+	// Наименование забирает ширину неиспользуемых колонок
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	used := 0.0
+	for _, col := range cols {
+		used += col.width
+	}
+	cols[1].width += pageWidth - left - right - used
+
+	drawListHeader(pdf, t, cols)
+
+	border := t.cellBorder()
+	pdf.SetFont(t.Fonts.Body, "", 10)
+	_, pageHeight := pdf.GetPageSize()
+	_, bottom := pdf.GetAutoPageBreak()
+	for i, item := range cart.Items {
+		if pdf.GetY()+listRowHeight > pageHeight-bottom {
+			pdf.AddPage()
+			drawListHeader(pdf, t, cols)
+			pdf.SetFont(t.Fonts.Body, "", 10)
+		}
+
+		row := []string{strconv.Itoa(i + 1), item.Name, item.SKU, strconv.Itoa(item.Quantity)}
+		if params.Price {
+			row = append(row, formatMoney(item.UnitPrice))
+		}
+		if params.Sum {
+			row = append(row, formatMoney(item.Total()))
+		}
+		fill := t.Table.Zebra && i%2 == 1
+		pdf.SetFillColor(t.Table.ZebraFill.R, t.Table.ZebraFill.G, t.Table.ZebraFill.B)
+		for j, col := range cols {
+			pdf.CellFormat(col.width, listRowHeight, fitText(pdf, row[j], col.width-2), border, 0, col.align, fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	if params.Sum {
+		totalWidth := 0.0
+		for _, col := range cols[:len(cols)-1] {
+			totalWidth += col.width
+		}
+		pdf.SetFont(t.Fonts.Body, "B", 10)
+		pdf.CellFormat(totalWidth, listRowHeight, "Итого", border, 0, "R", false, 0, "")
+		pdf.CellFormat(cols[len(cols)-1].width, listRowHeight, formatMoney(cart.Total()), border, 1, "R", false, 0, "")
+	}
+}
+
+const listRowHeight = 7.0
+
+type listColumn struct {
+	title string
+	width float64
+	align string
+}
+
+func drawListHeader(pdf *gofpdf.Fpdf, t Theme, cols []listColumn) {
+	pdf.SetFont(t.Fonts.Body, "B", 10)
+	pdf.SetDrawColor(t.Table.Border.R, t.Table.Border.G, t.Table.Border.B)
+	pdf.SetFillColor(t.Table.HeaderFill.R, t.Table.HeaderFill.G, t.Table.HeaderFill.B)
+	t.setTextColor(pdf, t.Table.HeaderText)
+	for _, col := range cols {
+		pdf.CellFormat(col.width, listRowHeight, col.title, t.cellBorder(), 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFillColor(255, 255, 255)
+	t.setTextColor(pdf, t.Palette.Text)
+}
+
+// fitText обрезает строку с многоточием, чтобы она поместилась в ширину ячейки
+func fitText(pdf *gofpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
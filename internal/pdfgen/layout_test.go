@@ -0,0 +1,194 @@
+package pdfgen
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+var update = flag.Bool("update", false, "перезаписать golden-файлы в testdata")
+
+func TestPlanSections(t *testing.T) {
+	tests := []struct {
+		params dto.PresentationParameters
+		want   []section
+	}{
+		{dto.PresentationParameters{}, []section{sectionCover, sectionOneByOne}},
+		{dto.PresentationParameters{List: true}, []section{sectionCover, sectionList}},
+		{dto.PresentationParameters{List: true, OneByOne: true}, []section{sectionCover, sectionList, sectionOneByOne}},
+		{dto.PresentationParameters{List: true, Sum: true}, []section{sectionCover, sectionList}},
+		{dto.PresentationParameters{OneByOne: true, Sum: true}, []section{sectionCover, sectionOneByOne, sectionTotals}},
+		{dto.PresentationParameters{Sum: true}, []section{sectionCover, sectionOneByOne, sectionTotals}},
+	}
+	for _, tt := range tests {
+		if got := planSections(tt.params); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("planSections(%+v) = %v, want %v", tt.params, got, tt.want)
+		}
+	}
+}
+
+// TestLayoutGolden сверяет текст страниц КП с testdata/layout_*.golden.
+// После намеренного изменения верстки: go test ./internal/pdfgen -run TestLayoutGolden -update
+func TestLayoutGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		params dto.PresentationParameters
+	}{
+		{"list", dto.PresentationParameters{List: true, Price: true, Sum: true}},
+		{"one_by_one", dto.PresentationParameters{OneByOne: true, Price: true}},
+		{"totals", dto.PresentationParameters{OneByOne: true, Price: true, Sum: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderText(t, testRequest(tt.params))
+			golden := filepath.Join("testdata", "layout_"+tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("текст КП не совпадает с %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func testRequest(params dto.PresentationParameters) dto.SaveRequest {
+	return dto.SaveRequest{
+		UserId:                 1,
+		PresentationParameters: params,
+		StyleTemplate:          dto.StyleTemplate{TemplateID: "classic"},
+		Cart: &dto.Cart{Items: []dto.LineItem{
+			{Name: "Кружка керамическая", SKU: "MUG-01", UnitPrice: 350, Quantity: 10},
+			{Name: "Футболка (хлопок)", SKU: "TS-02", Description: "Белая, размеры S-XL", UnitPrice: 990.5, Quantity: 3},
+			{Name: "Блокнот", SKU: "NB-03", UnitPrice: 120, Quantity: 25},
+		}},
+	}
+}
+
+// renderText рисует КП на фиксированную дату и возвращает текст по страницам
+func renderText(t *testing.T, req dto.SaveRequest) string {
+	t.Helper()
+	s, err := New(storage.NewMemory(), "pdfs", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _, err := s.prepare(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.date = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	pdf, err := s.layout(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if _, _, err := output(pdf, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return pageText(t, buf.Bytes())
+}
+
+// pageText достает строки из несжатых потоков страниц: gofpdf пишет текст
+// UTF-8 шрифтов как UTF-16BE, по строке на каждый блок BT ... ET
+func pageText(t *testing.T, data []byte) string {
+	t.Helper()
+	var out strings.Builder
+	page := 0
+	for {
+		i := bytes.Index(data, []byte("<</Type /Page\n"))
+		if i < 0 {
+			break
+		}
+		data = data[i+1:]
+		i = bytes.Index(data, []byte("<</Length "))
+		if i < 0 {
+			t.Fatal("не найден поток страницы")
+		}
+		j := bytes.Index(data[i:], []byte(">>\nstream\n"))
+		if j < 0 {
+			t.Fatal("не найден поток страницы")
+		}
+		n, err := strconv.Atoi(string(data[i+len("<</Length ") : i+j]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := i + j + len(">>\nstream\n")
+		content := data[start : start+n]
+		data = data[start+n:]
+
+		page++
+		fmt.Fprintf(&out, "--- страница %d ---\n", page)
+		for _, block := range splitBlocks(content) {
+			if line := strings.TrimSpace(decodeStrings(block)); line != "" {
+				out.WriteString(line + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+func splitBlocks(content []byte) [][]byte {
+	var blocks [][]byte
+	for {
+		i := bytes.Index(content, []byte("BT "))
+		if i < 0 {
+			return blocks
+		}
+		j := bytes.Index(content[i:], []byte(" ET"))
+		if j < 0 {
+			return blocks
+		}
+		blocks = append(blocks, content[i:i+j])
+		content = content[i+j:]
+	}
+}
+
+// decodeStrings склеивает все строки (...) блока
+func decodeStrings(block []byte) string {
+	var (
+		text []byte
+		in   bool
+	)
+	for i := 0; i < len(block); i++ {
+		c := block[i]
+		switch {
+		case !in && c == '(':
+			in = true
+		case in && c == '\\' && i+1 < len(block):
+			i++
+			if block[i] == 'r' {
+				text = append(text, '\r')
+			} else {
+				text = append(text, block[i])
+			}
+		case in && c == ')':
+			in = false
+		case in:
+			text = append(text, c)
+		}
+	}
+	units := make([]uint16, len(text)/2)
+	for i := range units {
+		units[i] = uint16(text[2*i])<<8 | uint16(text[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,104 @@
+package pdfgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/mockup"
+	"github.com/romapopov1212/robokp-pdf-service/internal/wordmark"
+	"image"
+	"strings"
+)
+
+// squareLogoMaxRatio до этого соотношения сторон области нанесения предпочитается квадратный логотип
+const squareLogoMaxRatio = 1.5
+
+// applyMockups собирает мокапы для позиций с областью нанесения и без готового mockup_image.
+// Возвращает копию корзины, в которой mockup_image указывает на собранное изображение в images.
+func (s *Page) applyMockups(req dto.SaveRequest, t Theme, cart *dto.Cart, images imageSet) (*dto.Cart, error) {
+	var result *dto.Cart
+	var textLogo image.Image
+	maxPixels := s.images.pixelLimit()
+
+	for i, item := range cart.Items {
+		if item.PrintArea == nil || item.MockupImage != "" || images[item.Image] == nil {
+			continue
+		}
+
+		logo, err := chooseLogo(*item.PrintArea, images[req.Logo.Square], images[req.Logo.Rectangle], maxPixels)
+		if err != nil {
+			return nil, err
+		}
+		if logo == nil && strings.TrimSpace(req.Logo.LogoText.Value) != "" {
+			if textLogo == nil {
+				textLogo, err = s.rasterWordmark(req.Logo.LogoText, wordmarkColor(req.StyleTemplate, t))
+				if errors.Is(err, wordmark.ErrEmptyText) {
+					// Текст из одних непечатаемых символов
+					return nil, validationErrorf("logo.logo_text: %v", err)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			logo = textLogo
+		}
+		if logo == nil {
+			continue
+		}
+
+		product, err := decodeAsset(images[item.Image], maxPixels)
+		if err != nil {
+			return nil, err
+		}
+		data, err := mockup.Composite(product, logo, *item.PrintArea)
+		if errors.Is(err, mockup.ErrInvalidArea) {
+			return nil, validationErrorf("cart.items[%d].print_area: %v", i, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сборки мокапа позиции %d: %w", i, err)
+		}
+		asset, err := prepareImage(data, maxPixels)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сборки мокапа позиции %d: %w", i, err)
+		}
+
+		if result == nil {
+			copied := *cart
+			copied.Items = append([]dto.LineItem(nil), cart.Items...)
+			result = &copied
+		}
+		key := "mockup:" + asset.name
+		images[key] = asset
+		result.Items[i].MockupImage = key
+	}
+
+	if result == nil {
+		return cart, nil
+	}
+	return result, nil
+}
+
+// chooseLogo выбирает логотип под форму области: для близкой к квадрату - квадратный, иначе прямоугольный
+func chooseLogo(area dto.PrintArea, square, rectangle *imageAsset, maxPixels int64) (image.Image, error) {
+	asset := rectangle
+	if square != nil && (rectangle == nil || area.Height > 0 && area.Width/area.Height < squareLogoMaxRatio) {
+		asset = square
+	}
+	if asset == nil {
+		return nil, nil
+	}
+	return decodeAsset(asset, maxPixels)
+}
+
+// decodeAsset декодирует подготовленное изображение, размер известен по заголовку заранее
+func decodeAsset(asset *imageAsset, maxPixels int64) (image.Image, error) {
+	if int64(asset.width)*int64(asset.height) > maxPixels {
+		return nil, fmt.Errorf("изображение %dx%d больше %d пикселей", asset.width, asset.height, maxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(asset.data))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования изображения: %w", err)
+	}
+	return img, nil
+}
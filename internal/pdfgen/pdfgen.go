@@ -3,57 +3,104 @@ package pdfgen
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/jung-kurt/gofpdf"
 	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"io"
+	"math"
+	"path"
 	"strconv"
-	"strings"
 	"time"
 )
 
+// presignTTL время жизни ссылки на скачивание сгенерированного PDF
+const presignTTL = 15 * time.Minute
+
+// Ключи пользовательских метаданных загруженного КП. sha256 известен только после вывода,
+// поэтому дописывается в метаданные отдельным запросом после загрузки
+const (
+	metaSHA256     = "sha256"
+	metaPages      = "pages"
+	metaTemplateID = "template-id"
+)
+
 type Page struct {
 	//HTML        string
-	s3Client    *s3.Client
-	s3Bucket    string
-	s3Region    string
-	s3UploadDir string
+	store     storage.Store
+	uploadDir string
+	fonts     *FontRegistry
+	carts     CartSource
+	executors ExecutorSource
+	templates *TemplateRegistry
+	images    *ImageFetcher
 }
 
-func New(s3Client *s3.Client, s3Bucket string, s3Region string, s3UploadDir string) *Page {
-	return &Page{
-		//HTML:        HTML,
-		s3Client:    s3Client,
-		s3Bucket:    s3Bucket,
-		s3Region:    s3Region,
-		s3UploadDir: s3UploadDir,
-	}
+// CartSource источник содержимого корзины по id_cart
+type CartSource interface {
+	GetCart(ctx context.Context, cartId int64) (*dto.Cart, error)
 }
 
-func initRussianFonts(pdf *gofpdf.Fpdf) {
-	// Используем встроенную поддержку UTF-8 в gofpdf
-	// Для кириллицы можно использовать стандартные шрифты с поддержкой UTF-8
-	pdf.SetFont("Arial", "", 12)
+// Result описывает сгенерированный и загруженный в S3 документ
+type Result struct {
+	Key     string
+	Size    int64
+	SHA256  string
+	URL     string
+	Content []byte
+	Pages   int
+	// TemplateID шаблон, которым фактически отрисован документ
+	TemplateID string
+	// Cart корзина, по которой отрисован документ: из запроса или загруженная по id_cart
+	Cart *dto.Cart
+	// InputHash хеш нормализованных входных данных, из него строится ключ в хранилище
+	InputHash string
+	// Deduplicated КП с такими входными данными уже был в хранилище и не перерисовывался
+	Deduplicated bool
+}
+
+func New(store storage.Store, uploadDir string, carts CartSource, executors ExecutorSource, images *ImageFetcher) (*Page, error) {
+	fonts, err := NewFontRegistry()
+	if err != nil {
+		return nil, err
+	}
+	
+	templates, err := NewTemplateRegistry(fonts)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &Page{
+		//HTML:        HTML,
+		store:     store,
+		uploadDir: uploadDir,
+		fonts:     fonts,
+		carts:     carts,
+		executors: executors,
+		templates: templates,
+		images:    images,
+	}, nil
 }
 
 // GeneratePDFWithGofpdf генерирует PDF используя gofpdf
-func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
+func (s *Page) GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 	pdf := gofpdf.New("P", "mm", "A4", "")
+	// Подключаем встроенные TTF-шрифты с кириллицей
+	logoFont := s.fonts.Resolve(req.Logo.LogoText.Font)
+	s.fonts.Register(pdf, logoFont)
 	pdf.AddPage()
 	
-	// Устанавливаем шрифт с поддержкой UTF-8
-	pdf.SetFont("Arial", "", 12)
-	
 	// Заголовок
-	pdf.SetFont("Arial", "B", 16)
+	pdf.SetFont(DefaultFontFamily, "B", 16)
 	pdf.SetTextColor(0, 0, 0)
 	pdf.Cell(0, 10, "PDF документ")
 	pdf.Ln(15)
 	
 	// Основной шрифт
-	pdf.SetFont("Arial", "", 12)
+	pdf.SetFont(DefaultFontFamily, "", 12)
 	
 	// Информация о пользователе
 	pdf.Cell(0, 8, "ID пользователя: "+strconv.FormatInt(req.UserId, 10))
@@ -67,7 +114,7 @@ func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 	
 	// Логотип текст
 	if req.Logo.LogoText.Value != "" {
-		pdf.SetFont("Arial", "", 12)
+		pdf.SetFont(logoFont, "", 12)
 		
 		// Применяем стили к тексту
 		style := ""
@@ -82,7 +129,7 @@ func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 		}
 		
 		if style != "" {
-			pdf.SetFont("Arial", style, 12)
+			pdf.SetFont(logoFont, style, 12)
 		}
 		
 		pdf.Cell(0, 8, req.Logo.LogoText.Value)
@@ -90,11 +137,11 @@ func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 	}
 	
 	// Параметры исполнителя
-	pdf.SetFont("Arial", "B", 14)
+	pdf.SetFont(DefaultFontFamily, "B", 14)
 	pdf.Cell(0, 10, "Параметры исполнителя:")
 	pdf.Ln(10)
 	
-	pdf.SetFont("Arial", "", 12)
+	pdf.SetFont(DefaultFontFamily, "", 12)
 	pdf.Cell(0, 8, "Показать логотип: "+strconv.FormatBool(req.ExecutorParameters.First.ShowLogo))
 	pdf.Ln(8)
 	pdf.Cell(0, 8, "Показать имя: "+req.ExecutorParameters.First.ShowName)
@@ -103,11 +150,11 @@ func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 	pdf.Ln(15)
 	
 	// Параметры презентации
-	pdf.SetFont("Arial", "B", 14)
+	pdf.SetFont(DefaultFontFamily, "B", 14)
 	pdf.Cell(0, 10, "Параметры презентации:")
 	pdf.Ln(10)
 	
-	pdf.SetFont("Arial", "", 12)
+	pdf.SetFont(DefaultFontFamily, "", 12)
 	pdf.Cell(0, 8, "Список: "+strconv.FormatBool(req.PresentationParameters.List))
 	pdf.Ln(8)
 	pdf.Cell(0, 8, "По одному: "+strconv.FormatBool(req.PresentationParameters.OneByOne))
@@ -118,11 +165,11 @@ func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 	pdf.Ln(15)
 	
 	// Шаблон стиля
-	pdf.SetFont("Arial", "B", 14)
+	pdf.SetFont(DefaultFontFamily, "B", 14)
 	pdf.Cell(0, 10, "Шаблон стиля:")
 	pdf.Ln(10)
 	
-	pdf.SetFont("Arial", "", 12)
+	pdf.SetFont(DefaultFontFamily, "", 12)
 	pdf.Cell(0, 8, "ID шаблона: "+req.StyleTemplate.TemplateID)
 	pdf.Ln(8)
 	if req.StyleTemplate.Color != "" {
@@ -133,216 +180,424 @@ func GeneratePDFWithGofpdf(filename string, req dto.SaveRequest) error {
 	return pdf.OutputFileAndClose(filename)
 }
 
-// GenerateAdvancedPDFWithGofpdf создает более продвинутый PDF с таблицами и изображениями
-func (s *Page) GenerateAdvancedPDFWithGofpdf(req dto.SaveRequest) error {
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	
-	// Устанавливаем шрифт с поддержкой UTF-8
-	pdf.SetFont("Arial", "", 12)
-	
-	// Заголовок с цветом
-	if req.StyleTemplate.Color != "" {
-		// Парсим цвет (предполагаем формат "#RRGGBB")
-		color := req.StyleTemplate.Color
-		if strings.HasPrefix(color, "#") {
-			color = color[1:] // Убираем #
+// resolveCart берет корзину из запроса, а если ее нет - загружает по id_cart
+func (s *Page) resolveCart(ctx context.Context, req dto.SaveRequest) (*dto.Cart, error) {
+	cart := req.Cart
+	if cart == nil {
+		if s.carts == nil {
+			return nil, validationErrorf("корзина не передана в запросе")
 		}
-		if len(color) == 6 {
-			r, _ := strconv.ParseUint(color[0:2], 16, 8)
-			g, _ := strconv.ParseUint(color[2:4], 16, 8)
-			b, _ := strconv.ParseUint(color[4:6], 16, 8)
-			pdf.SetTextColor(int(r), int(g), int(b))
+		
+		fetched, err := s.carts.GetCart(ctx, req.CartId)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки корзины %d: %w", req.CartId, err)
 		}
+		cart = fetched
 	}
 	
-	pdf.SetFont("Arial", "B", 18)
-	pdf.Cell(0, 15, "Документ PDF")
-	pdf.Ln(20)
-	
-	// Сброс цвета на черный
-	pdf.SetTextColor(0, 0, 0)
-	
-	// Информационная таблица
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(0, 10, "Основная информация")
-	pdf.Ln(12)
+	if len(cart.Items) == 0 {
+		return nil, validationErrorf("корзина %d пуста", req.CartId)
+	}
+	if cart.ID == 0 {
+		cart.ID = req.CartId
+	}
 	
-	// Создаем таблицу
-	header := []string{"Поле", "Значение"}
-	data := [][]string{
-		{"ID пользователя", strconv.FormatInt(req.UserId, 10)},
-		{"ID корзины", strconv.FormatInt(req.CartId, 10)},
-		{"ID публикации", strconv.FormatInt(req.PublicationId, 10)},
-		{"Количество", strconv.Itoa(req.Count)},
+	return cart, nil
+}
+
+// document входные данные для отрисовки одного КП
+type document struct {
+	req      dto.SaveRequest
+	cart     *dto.Cart
+	logoFont string
+	executor *dto.Executor
+	theme    Theme
+	images   imageSet
+	// date дата КП на титуле, начало дня: входит в хеш, иначе дедупликация отдала бы КП со старой датой
+	date time.Time
+}
+
+// Render отрисовывает КП в памяти, Key и URL результата не заполняются
+func (s *Page) Render(ctx context.Context, req dto.SaveRequest) (*Result, error) {
+	doc, hash, err := s.prepare(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 	
-	createTable(pdf, header, data)
-	pdf.Ln(15)
+	res, err := s.render(doc)
+	if err != nil {
+		return nil, err
+	}
+	res.InputHash = hash
+	return res, nil
+}
+
+// prepare собирает входные данные КП: корзину, исполнителя, шаблон и картинки,
+// и считает по ним канонический хеш
+func (s *Page) prepare(ctx context.Context, req dto.SaveRequest) (document, string, error) {
+	cart, err := s.resolveCart(ctx, req)
+	if err != nil {
+		return document{}, "", err
+	}
 	
-	// Логотип и текст
-	if req.Logo.LogoText.Value != "" {
-		pdf.SetFont("Arial", "B", 14)
-		pdf.Cell(0, 10, "Логотип")
-		pdf.Ln(12)
-		
-		// Применяем стили к тексту логотипа
-		style := ""
-		if req.Logo.LogoText.Bold {
-			style += "B"
-		}
-		if req.Logo.LogoText.Kursive {
-			style += "I"
-		}
-		if req.Logo.LogoText.Under {
-			style += "U"
-		}
-		
-		pdf.SetFont("Arial", style, 12)
-		pdf.Cell(0, 8, req.Logo.LogoText.Value)
-		pdf.Ln(15)
-		
-		// Добавляем изображения логотипа если они есть
-		if req.Logo.Square != "" {
-			addImageFromBase64(pdf, req.Logo.Square, "Логотип (квадрат)", 30)
-		}
-		if req.Logo.Rectangle != "" {
-			addImageFromBase64(pdf, req.Logo.Rectangle, "Логотип (прямоугольник)", 30)
-		}
+	executor, err := s.resolveExecutor(ctx, req.UserId)
+	if err != nil {
+		return document{}, "", err
 	}
 	
-	// Параметры исполнителя в таблице
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(0, 10, "Параметры исполнителя")
-	pdf.Ln(12)
+	theme, err := s.templates.Resolve(req.StyleTemplate)
+	if err != nil {
+		return document{}, "", err
+	}
 	
-	execHeader := []string{"Параметр", "Значение"}
-	execData := [][]string{
-		{"Показать логотип", strconv.FormatBool(req.ExecutorParameters.First.ShowLogo)},
-		{"Показать имя", req.ExecutorParameters.First.ShowName},
-		{"Показать контакты", req.ExecutorParameters.First.ShowContacts},
+	images, err := s.prepareImages(ctx, req, cart, executor)
+	if err != nil {
+		return document{}, "", err
 	}
 	
-	createTable(pdf, execHeader, execData)
-	pdf.Ln(15)
+	doc := document{
+		images:   images,
+		theme:    theme,
+		req:      req,
+		cart:     cart,
+		logoFont: s.fonts.Resolve(req.Logo.LogoText.Font),
+		executor: executor,
+		date:     today(),
+	}
 	
-	// Параметры презентации в таблице
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(0, 10, "Параметры презентации")
-	pdf.Ln(12)
+	hash, err := s.inputHash(doc)
+	if err != nil {
+		return document{}, "", err
+	}
+	return doc, hash, nil
+}
+
+// today начало текущего дня по локальному времени
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// render отрисовывает подготовленный документ в память
+func (s *Page) render(doc document) (*Result, error) {
+	pdf, err := s.layout(doc)
+	if err != nil {
+		return nil, err
+	}
 	
-	presentHeader := []string{"Параметр", "Значение"}
-	presentData := [][]string{
-		{"Список", strconv.FormatBool(req.PresentationParameters.List)},
-		{"По одному", strconv.FormatBool(req.PresentationParameters.OneByOne)},
-		{"Сумма", strconv.FormatBool(req.PresentationParameters.Sum)},
-		{"Цена", strconv.FormatBool(req.PresentationParameters.Price)},
+	var buf bytes.Buffer
+	size, sum, err := output(pdf, &buf)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Size:       size,
+		SHA256:     sum,
+		Content:    buf.Bytes(),
+		Pages:      pdf.PageNo(),
+		TemplateID: doc.theme.ID,
+		Cart:       doc.cart,
+	}, nil
+}
+
+// layout собирает мокапы и раскладывает документ по страницам, PDF еще не выведен
+func (s *Page) layout(doc document) (*gofpdf.Fpdf, error) {
+	cart, err := s.applyMockups(doc.req, doc.theme, doc.cart, doc.images)
+	if err != nil {
+		return nil, err
 	}
+	doc.cart = cart
 	
-	createTable(pdf, presentHeader, presentData)
-	pdf.Ln(15)
+	pdf := s.draw(doc, 0)
+	// Колонтитул последней страницы зависит от общего числа страниц,
+	// поэтому при отличающемся Last документ рисуется второй раз
+	if doc.executor != nil && doc.req.ExecutorParameters.Last != doc.req.ExecutorParameters.All && pdf.Ok() {
+		pdf = s.draw(doc, pdf.PageNo())
+	}
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("ошибка при генерации PDF: %w", err)
+	}
+	return pdf, nil
+}
+
+// output пишет PDF в w, считая размер и sha256 выведенного
+func output(pdf *gofpdf.Fpdf, w io.Writer) (int64, string, error) {
+	h := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(w, h)}
+	if err := pdf.Output(counter); err != nil {
+		return 0, "", fmt.Errorf("ошибка при генерации PDF: %w", err)
+	}
+	return counter.n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// draw выполняет один проход отрисовки, totalPages == 0 - число страниц еще неизвестно
+func (s *Page) draw(doc document, totalPages int) *gofpdf.Fpdf {
+	t := doc.theme
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	// Дата создания из входных данных: одинаковый вход дает побайтно одинаковый PDF
+	pdf.SetCreationDate(doc.date)
+	// Подключаем встроенные TTF-шрифты с кириллицей
+	s.fonts.Register(pdf, doc.logoFont, t.Fonts.Heading, t.Fonts.Body)
+	pdf.SetMargins(t.Margins.Left, t.Margins.Top, t.Margins.Right)
+	pdf.SetAutoPageBreak(true, t.Margins.Bottom)
+	if doc.executor != nil {
+		setupExecutorHeaderFooter(pdf, t, doc.executor, doc.req.ExecutorParameters, doc.images, totalPages)
+	}
 	
-	// Шаблон стиля
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(0, 10, "Шаблон стиля")
-	pdf.Ln(12)
+	params := doc.req.PresentationParameters
+	for _, sec := range planSections(params) {
+		switch sec {
+		case sectionCover:
+			renderCover(pdf, t, doc.req, doc.cart, doc.logoFont, doc.images, doc.date)
+		case sectionList:
+			renderList(pdf, t, doc.cart, params)
+		case sectionOneByOne:
+			for _, item := range doc.cart.Items {
+				renderProductPage(pdf, t, item, params, doc.images)
+			}
+		case sectionTotals:
+			renderTotals(pdf, t, doc.cart)
+		}
+	}
 	
-	styleHeader := []string{"Параметр", "Значение"}
-	styleData := [][]string{
-		{"ID шаблона", req.StyleTemplate.TemplateID},
+	return pdf
+}
+
+// prepareImages заранее декодирует все изображения документа, чтобы ошибки в них
+// вернуть клиенту до начала отрисовки
+func (s *Page) prepareImages(ctx context.Context, req dto.SaveRequest, cart *dto.Cart, executor *dto.Executor) (imageSet, error) {
+	images := make(imageSet)
+	if err := images.add(ctx, s.images, "logo.logo_square", req.Logo.Square); err != nil {
+		return nil, err
 	}
-	if req.StyleTemplate.Color != "" {
-		styleData = append(styleData, []string{"Цвет", req.StyleTemplate.Color})
+	if err := images.add(ctx, s.images, "logo.logo_rectangle", req.Logo.Rectangle); err != nil {
+		return nil, err
+	}
+	for i, item := range cart.Items {
+		if err := images.add(ctx, s.images, fmt.Sprintf("cart.items[%d].image", i), item.Image); err != nil {
+			return nil, err
+		}
+		if err := images.add(ctx, s.images, fmt.Sprintf("cart.items[%d].mockup_image", i), item.MockupImage); err != nil {
+			return nil, err
+		}
+	}
+	if executor != nil {
+		if err := images.add(ctx, s.images, "executor.logo", executor.Logo); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// resolveExecutor загружает профиль исполнителя, отсутствие профиля - не ошибка, колонтитулы просто не рисуются
+func (s *Page) resolveExecutor(ctx context.Context, userId int64) (*dto.Executor, error) {
+	if s.executors == nil {
+		return nil, nil
 	}
 	
-	createTable(pdf, styleHeader, styleData)
+	executor, err := s.executors.FindExecutor(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки исполнителя %d: %w", userId, err)
+	}
 	
-	var buf bytes.Buffer
-	err := pdf.Output(&buf)
+	return executor, nil
+}
+
+// GenerateAdvancedPDFWithGofpdf рисует КП и загружает его в хранилище под ключом
+// upload_dir/<хеш входных данных>.pdf. Если такой объект уже есть, КП не перерисовывается:
+// результат собирается из метаданных объекта. Content в результате всегда пуст,
+// PDF выводится сразу в хранилище.
+func (s *Page) GenerateAdvancedPDFWithGofpdf(ctx context.Context, req dto.SaveRequest) (*Result, error) {
+	doc, hash, err := s.prepare(ctx, req)
 	if err != nil {
-		return fmt.Errorf("ошибка при генерации PDF: %w", err)
+		return nil, err
 	}
+	key := path.Join(s.uploadDir, hash+".pdf")
 	
-	pdfReader := bytes.NewReader(buf.Bytes())
+	res, err := s.existing(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res, err = s.upload(ctx, key, doc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		res.TemplateID = doc.theme.ID
+		res.Cart = doc.cart
+	}
 	
-	s3Key := fmt.Sprintf("%s/%s_%d.pdf",
-		s.s3UploadDir,
-		req.CartId,
-		time.Now().UnixNano())
+	url, err := s.store.PresignGet(ctx, key, presignTTL, storage.PresignOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании ссылки на PDF: %w", err)
+	}
 	
-	_, err = s.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(s.s3Bucket),
-		Key:         aws.String(s3Key),
-		Body:        pdfReader,
-		ContentType: aws.String("application/pdf"),
+	res.Key = key
+	res.URL = url
+	res.InputHash = hash
+	return res, nil
+}
+
+// upload выводит PDF через io.Pipe прямо в хранилище, не собирая вторую копию документа
+// в памяти. Ошибка вывода передается читателю, и хранилище отменяет загрузку: S3 прерывает
+// multipart upload, частичный объект не остается.
+func (s *Page) upload(ctx context.Context, key string, doc document) (*Result, error) {
+	pdf, err := s.layout(doc)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{
+		Pages:      pdf.PageNo(),
+		TemplateID: doc.theme.ID,
+		Cart:       doc.cart,
+	}
+	
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		res.Size, res.SHA256, err = output(pdf, pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	
+	// sha256 известен только после вывода, поэтому при загрузке в метаданные попадает лишь число страниц
+	err = s.store.Put(ctx, key, pr, storage.PutOptions{
+		ContentType: "application/pdf",
+		Metadata:    pdfMetadata(res.Pages, res.TemplateID, ""),
 	})
+	// Если хранилище перестало читать, вывод разблокируется с ошибкой
+	pr.CloseWithError(err)
+	if outErr := <-done; outErr != nil && err == nil {
+		err = outErr
+	}
 	if err != nil {
-		//s.logger.Error("ошибка при сохранении PDF в S3", zap.Error(err))
-		return fmt.Errorf("ошибка при сохранении PDF в S3: %w", err)
+		return nil, fmt.Errorf("ошибка при сохранении PDF: %w", err)
 	}
-	return nil
-	//return buf.Bytes(), nil
 	
-	//return pdf.OutputFileAndClose(filename)
+	// Без sha256 в метаданных повторный запрос прочитал бы объект целиком
+	err = s.store.SetMetadata(ctx, key, storage.PutOptions{
+		ContentType: "application/pdf",
+		Metadata:    pdfMetadata(res.Pages, res.TemplateID, res.SHA256),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении PDF: %w", err)
+	}
+	return res, nil
+}
+
+func pdfMetadata(pages int, templateID, sum string) map[string]string {
+	metadata := map[string]string{
+		metaPages:      strconv.Itoa(pages),
+		metaTemplateID: templateID,
+	}
+	if sum != "" {
+		metadata[metaSHA256] = sum
+	}
+	return metadata
 }
 
-// createTable создает таблицу в PDF
-func createTable(pdf *gofpdf.Fpdf, header []string, data [][]string) {
-	// Ширина колонок
-	colWidth := 80.0
+// existing проверяет, загружен ли уже КП с таким ключом. Объект без метаданных
+// (загружен старой версией сервиса) считается отсутствующим
+func (s *Page) existing(ctx context.Context, key string) (*Result, error) {
+	obj, err := s.store.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при проверке PDF в хранилище: %w", err)
+	}
 	
-	// Заголовок таблицы
-	pdf.SetFont("Arial", "B", 10)
-	pdf.SetFillColor(240, 240, 240)
-	for _, col := range header {
-		pdf.CellFormat(colWidth, 7, col, "1", 0, "", true, 0, "")
+	pages, err := strconv.Atoi(obj.Metadata[metaPages])
+	if err != nil {
+		return nil, nil
 	}
-	pdf.Ln(-1)
 	
-	// Данные таблицы
-	pdf.SetFont("Arial", "", 10)
-	pdf.SetFillColor(255, 255, 255)
-	for _, row := range data {
-		for _, col := range row {
-			pdf.CellFormat(colWidth, 6, col, "1", 0, "", false, 0, "")
+	sum := obj.Metadata[metaSHA256]
+	if sum == "" {
+		// Загрузка прервалась до записи sha256: считаем один раз и дописываем
+		sum, err = s.checksum(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		err = s.store.SetMetadata(ctx, key, storage.PutOptions{
+			ContentType: "application/pdf",
+			Metadata:    pdfMetadata(pages, obj.Metadata[metaTemplateID], sum),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при сохранении PDF: %w", err)
 		}
-		pdf.Ln(-1)
 	}
+	return &Result{
+		Size:         obj.Size,
+		SHA256:       sum,
+		Pages:        pages,
+		Deduplicated: true,
+	}, nil
 }
 
-// addImageFromBase64 добавляет изображение из base64 строки
-func addImageFromBase64(pdf *gofpdf.Fpdf, base64Data, caption string, height float64) {
-	// Убираем префикс data:image/...;base64, если есть
-	if strings.Contains(base64Data, ",") {
-		base64Data = strings.Split(base64Data, ",")[1]
+// checksum sha256 объекта в хранилище: читается потоком, без отрисовки
+func (s *Page) checksum(ctx context.Context, key string) (string, error) {
+	body, _, err := s.store.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при чтении PDF из хранилища: %w", err)
 	}
+	defer body.Close()
 	
-	// Декодируем base64
-	imageData, err := base64.StdEncoding.DecodeString(base64Data)
-	if err != nil {
-		return // Игнорируем ошибки декодирования
-	}
-	
-	// Определяем тип изображения по первым байтам
-	var imageType string
-	if len(imageData) > 2 {
-		switch {
-		case imageData[0] == 0xFF && imageData[1] == 0xD8:
-			imageType = "JPEG"
-		case imageData[0] == 0x89 && imageData[1] == 0x50:
-			imageType = "PNG"
-		default:
-			return // Неподдерживаемый формат
-		}
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", fmt.Errorf("ошибка при чтении PDF из хранилища: %w", err)
 	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Open открывает загруженный КП, например, чтобы отдать повторно отрисованный результат без Content
+func (s *Page) Open(ctx context.Context, key string) (io.ReadCloser, *storage.Object, error) {
+	return s.store.Get(ctx, key)
+}
+
+// createTable создает таблицу в PDF в стиле шаблона
+func createTable(pdf *gofpdf.Fpdf, t Theme, header []string, data [][]string) {
+	// Ширина колонок, не больше ширины страницы
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	colWidth := math.Min(80, (pageWidth-left-right)/float64(len(header)))
+	border := t.cellBorder()
+	pdf.SetDrawColor(t.Table.Border.R, t.Table.Border.G, t.Table.Border.B)
 	
-	// Добавляем изображение
-	pdf.Image("", pdf.GetX(), pdf.GetY(), 0, height, false, imageType, 0, "")
+	// Заголовок таблицы
+	pdf.SetFont(t.Fonts.Body, "B", 10)
+	pdf.SetFillColor(t.Table.HeaderFill.R, t.Table.HeaderFill.G, t.Table.HeaderFill.B)
+	t.setTextColor(pdf, t.Table.HeaderText)
+	for _, col := range header {
+		pdf.CellFormat(colWidth, 7, col, border, 0, "", true, 0, "")
+	}
+	pdf.Ln(-1)
+	t.setTextColor(pdf, t.Palette.Text)
 	
-	// Добавляем подпись
-	pdf.SetFont("Arial", "", 10)
-	pdf.Cell(0, 5, caption)
-	pdf.Ln(10)
+	// Данные таблицы
+	pdf.SetFont(t.Fonts.Body, "", 10)
+	for i, row := range data {
+		fill := t.Table.Zebra && i%2 == 1
+		pdf.SetFillColor(t.Table.ZebraFill.R, t.Table.ZebraFill.G, t.Table.ZebraFill.B)
+		for _, col := range row {
+			pdf.CellFormat(colWidth, 6, col, border, 0, "", fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.SetFillColor(255, 255, 255)
 }
 
 // GeneratePDF - оставляем старую функцию для совместимости, но теперь она использует gofpdf
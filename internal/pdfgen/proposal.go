@@ -0,0 +1,222 @@
+package pdfgen
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// renderCover рисует титульную страницу КП в раскладке шаблона
+func renderCover(pdf *gofpdf.Fpdf, t Theme, req dto.SaveRequest, cart *dto.Cart, logoFont string, images imageSet, date time.Time) {
+	pdf.AddPage()
+
+	align := "C"
+	switch t.Cover.Layout {
+	case "band":
+		pageWidth, _ := pdf.GetPageSize()
+		pdf.SetFillColor(t.Palette.Accent.R, t.Palette.Accent.G, t.Palette.Accent.B)
+		pdf.Rect(0, 0, pageWidth, 90, "F")
+		pdf.SetY(40)
+		pdf.SetTextColor(255, 255, 255)
+	case "left":
+		align = "L"
+		left, _, _, _ := pdf.GetMargins()
+		pdf.SetFillColor(t.Palette.Accent.R, t.Palette.Accent.G, t.Palette.Accent.B)
+		pdf.Rect(left, 55, 3, 40, "F")
+		pdf.SetLeftMargin(left + 8)
+		defer pdf.SetLeftMargin(left)
+		pdf.SetY(60)
+		t.setTextColor(pdf, t.Palette.Accent)
+	default:
+		pdf.SetY(60)
+		t.setTextColor(pdf, t.Palette.Accent)
+	}
+
+	pdf.SetFont(t.Fonts.Heading, "B", 26)
+	pdf.CellFormat(0, 14, "Коммерческое предложение", "", 1, align, false, 0, "")
+	if t.Cover.Layout == "band" {
+		pdf.SetY(100)
+	}
+	t.setTextColor(pdf, t.Palette.Text)
+	pdf.Ln(6)
+
+	// Логотип клиента
+	if req.Logo.LogoText.Value != "" {
+		pageWidth, _ := pdf.GetPageSize()
+		left, _, right, _ := pdf.GetMargins()
+		drawWordmark(pdf, req.Logo.LogoText, logoFont, wordmarkColor(req.StyleTemplate, t), pageWidth-left-right, 20, align)
+		t.setTextColor(pdf, t.Palette.Text)
+		pdf.Ln(4)
+	}
+	renderLogos(pdf, images[req.Logo.Square], images[req.Logo.Rectangle], align)
+
+	pdf.SetFont(t.Fonts.Body, "", 12)
+	t.setTextColor(pdf, t.Palette.Muted)
+	pdf.CellFormat(0, 8, "Корзина № "+strconv.FormatInt(cart.ID, 10), "", 1, align, false, 0, "")
+	pdf.CellFormat(0, 8, "Позиций: "+strconv.Itoa(len(cart.Items)), "", 1, align, false, 0, "")
+	pdf.CellFormat(0, 8, date.Format("02.01.2006"), "", 1, align, false, 0, "")
+	t.setTextColor(pdf, t.Palette.Text)
+}
+
+// renderProductPage рисует страницу одной позиции корзины с крупным мокапом
+func renderProductPage(pdf *gofpdf.Fpdf, t Theme, item dto.LineItem, params dto.PresentationParameters, images imageSet) {
+	pdf.AddPage()
+
+	pdf.SetFont(t.Fonts.Heading, "B", 16)
+	t.setTextColor(pdf, t.Palette.Accent)
+	pdf.MultiCell(0, 8, item.Name, "", "L", false)
+	t.setTextColor(pdf, t.Palette.Text)
+	if item.SKU != "" {
+		pdf.SetFont(t.Fonts.Body, "", 10)
+		t.setTextColor(pdf, t.Palette.Muted)
+		pdf.Cell(0, 6, "Артикул: "+item.SKU)
+		pdf.Ln(8)
+		t.setTextColor(pdf, t.Palette.Text)
+	}
+	pdf.Ln(4)
+
+	// Мокап с логотипом приоритетнее обычного фото товара
+	image := item.MockupImage
+	if image == "" {
+		image = item.Image
+	}
+	if asset := images[image]; asset != nil {
+		pageWidth, _ := pdf.GetPageSize()
+		left, _, right, _ := pdf.GetMargins()
+		// Изображение центрируется в рамке по вертикали, поэтому текст идет после всей рамки
+		y := pdf.GetY()
+		placeImage(pdf, asset, left, y, pageWidth-left-right, 140, "C")
+		pdf.SetY(y + 140 + 6)
+	}
+
+	if item.Description != "" {
+		pdf.SetFont(t.Fonts.Body, "", 11)
+		pdf.MultiCell(0, 6, item.Description, "", "L", false)
+		pdf.Ln(4)
+	}
+
+	header := []string{"Количество"}
+	row := []string{strconv.Itoa(item.Quantity)}
+	if params.Price {
+		header = append(header, "Цена за шт.")
+		row = append(row, formatMoney(item.UnitPrice))
+	}
+	if params.Sum {
+		header = append(header, "Сумма")
+		row = append(row, formatMoney(item.Total()))
+	}
+	createTable(pdf, t, header, [][]string{row})
+}
+
+// renderTotals рисует итоговый блок КП
+func renderTotals(pdf *gofpdf.Fpdf, t Theme, cart *dto.Cart) {
+	pdf.Ln(10)
+	pdf.SetFont(t.Fonts.Heading, "B", 14)
+	t.setTextColor(pdf, t.Palette.Accent)
+	pdf.Cell(0, 10, "Итого")
+	t.setTextColor(pdf, t.Palette.Text)
+	pdf.Ln(12)
+
+	quantity := 0
+	for _, item := range cart.Items {
+		quantity += item.Quantity
+	}
+
+	createTable(pdf, t, []string{"Параметр", "Значение"}, [][]string{
+		{"Позиций", strconv.Itoa(len(cart.Items))},
+		{"Единиц товара", strconv.Itoa(quantity)},
+		{"Общая сумма", formatMoney(cart.Total())},
+	})
+}
+
+// renderLogos рисует квадратный и прямоугольный логотипы клиента в одну строку
+func renderLogos(pdf *gofpdf.Fpdf, square, rectangle *imageAsset, align string) {
+	const (
+		logoHeight = 30.0
+		gap        = 10.0
+	)
+	type logo struct {
+		asset *imageAsset
+		width float64
+	}
+	var logos []logo
+	if square != nil {
+		logos = append(logos, logo{square, logoHeight})
+	}
+	if rectangle != nil {
+		logos = append(logos, logo{rectangle, logoHeight * 2})
+	}
+	if len(logos) == 0 {
+		return
+	}
+
+	total := gap * float64(len(logos)-1)
+	for _, l := range logos {
+		total += l.width
+	}
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	x := left
+	if align == "C" {
+		x = left + (pageWidth-left-right-total)/2
+	}
+
+	y := pdf.GetY()
+	for _, l := range logos {
+		placeImage(pdf, l.asset, x, y, l.width, logoHeight, align)
+		x += l.width + gap
+	}
+	pdf.SetY(y + logoHeight + 6)
+}
+
+func logoTextStyle(text dto.LogoText) string {
+	style := ""
+	if text.Bold {
+		style += "B"
+	}
+	if text.Kursive {
+		style += "I"
+	}
+	if text.Under {
+		style += "U"
+	}
+	return style
+}
+
+// parseHexColor разбирает цвет в формате "#RRGGBB"
+func parseHexColor(color string) (int, int, int, bool) {
+	color = strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(color) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(color, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16 & 0xFF), int(v >> 8 & 0xFF), int(v & 0xFF), true
+}
+
+// formatMoney форматирует сумму в рублях: "1 234 567,89 руб."
+func formatMoney(v float64) string {
+	kopecks := int64(math.Round(v * 100))
+	sign := ""
+	if kopecks < 0 {
+		sign = "-"
+		kopecks = -kopecks
+	}
+
+	rubles := strconv.FormatInt(kopecks/100, 10)
+	var grouped strings.Builder
+	for i, r := range rubles {
+		if i > 0 && (len(rubles)-i)%3 == 0 {
+			grouped.WriteRune(' ')
+		}
+		grouped.WriteRune(r)
+	}
+
+	return fmt.Sprintf("%s%s,%02d руб.", sign, grouped.String(), kopecks%100)
+}
@@ -0,0 +1,152 @@
+package pdfgen
+
+import (
+	"embed"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// DefaultTemplateID шаблон, который используется, если id_template не передан
+const DefaultTemplateID = "classic"
+
+//go:embed templates/*.yaml
+var templateFiles embed.FS
+
+// Color цвет RGB, в YAML задается строкой "#RRGGBB"
+type Color struct {
+	R, G, B int
+}
+
+func (c *Color) UnmarshalYAML(value *yaml.Node) error {
+	r, g, b, ok := parseHexColor(value.Value)
+	if !ok {
+		return fmt.Errorf("невалидный цвет %q", value.Value)
+	}
+	*c = Color{R: r, G: g, B: b}
+	return nil
+}
+
+// Theme оформление КП, описывается одним файлом в templates/
+type Theme struct {
+	ID      string `yaml:"id"`
+	Name    string `yaml:"name"`
+	Version int    `yaml:"version"`
+	Fonts   struct {
+		Heading string `yaml:"heading"`
+		Body    string `yaml:"body"`
+	} `yaml:"fonts"`
+	Palette struct {
+		Accent Color `yaml:"accent"`
+		Text   Color `yaml:"text"`
+		Muted  Color `yaml:"muted"`
+	} `yaml:"palette"`
+	Margins struct {
+		Left   float64 `yaml:"left"`
+		Top    float64 `yaml:"top"`
+		Right  float64 `yaml:"right"`
+		Bottom float64 `yaml:"bottom"`
+	} `yaml:"margins"`
+	Cover struct {
+		Layout string `yaml:"layout"` // center, left, band
+	} `yaml:"cover"`
+	Table struct {
+		HeaderFill Color  `yaml:"header_fill"`
+		HeaderText Color  `yaml:"header_text"`
+		Border     Color  `yaml:"border"`
+		Borders    string `yaml:"borders"` // all, horizontal
+		Zebra      bool   `yaml:"zebra"`
+		ZebraFill  Color  `yaml:"zebra_fill"`
+	} `yaml:"table"`
+	HeaderFooter struct {
+		Rule       bool `yaml:"rule"`
+		AccentName bool `yaml:"accent_name"`
+	} `yaml:"header_footer"`
+}
+
+func (t Theme) setTextColor(pdf *gofpdf.Fpdf, c Color) {
+	pdf.SetTextColor(c.R, c.G, c.B)
+}
+
+// cellBorder рамка ячейки таблицы в нотации gofpdf
+func (t Theme) cellBorder() string {
+	if t.Table.Borders == "horizontal" {
+		return "B"
+	}
+	return "1"
+}
+
+// TemplateRegistry набор шаблонов оформления по id_template
+type TemplateRegistry struct {
+	themes map[string]Theme
+}
+
+func NewTemplateRegistry(fonts *FontRegistry) (*TemplateRegistry, error) {
+	files, err := fs.Glob(templateFiles, "templates/*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения шаблонов: %w", err)
+	}
+
+	r := &TemplateRegistry{themes: make(map[string]Theme)}
+	for _, file := range files {
+		data, err := templateFiles.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения шаблона %s: %w", file, err)
+		}
+
+		var theme Theme
+		if err := yaml.Unmarshal(data, &theme); err != nil {
+			return nil, fmt.Errorf("ошибка разбора шаблона %s: %w", file, err)
+		}
+		if theme.ID == "" {
+			return nil, fmt.Errorf("у шаблона %s не задан id", file)
+		}
+		theme.Fonts.Heading = fonts.Resolve(theme.Fonts.Heading)
+		theme.Fonts.Body = fonts.Resolve(theme.Fonts.Body)
+
+		r.themes[theme.ID] = theme
+	}
+
+	if _, ok := r.themes[DefaultTemplateID]; !ok {
+		return nil, fmt.Errorf("не найден шаблон по умолчанию %s", DefaultTemplateID)
+	}
+
+	return r, nil
+}
+
+// Resolve возвращает тему для style_template, color переопределяет акцентный цвет шаблона
+func (r *TemplateRegistry) Resolve(style dto.StyleTemplate) (Theme, error) {
+	id := strings.TrimSpace(style.TemplateID)
+	if id == "" {
+		id = DefaultTemplateID
+	}
+
+	theme, ok := r.themes[id]
+	if !ok {
+		return Theme{}, validationErrorf("неизвестный шаблон %q", style.TemplateID)
+	}
+
+	if style.Color != "" {
+		red, green, blue, ok := parseHexColor(style.Color)
+		if !ok {
+			return Theme{}, validationErrorf("невалидный цвет %q, ожидается #RRGGBB", style.Color)
+		}
+		theme.Palette.Accent = Color{R: red, G: green, B: blue}
+	}
+
+	return theme, nil
+}
+
+// IDs возвращает отсортированный список доступных шаблонов
+func (r *TemplateRegistry) IDs() []string {
+	ids := make([]string, 0, len(r.themes))
+	for id := range r.themes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,65 @@
+package pdfgen
+
+import (
+	"github.com/jung-kurt/gofpdf"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/wordmark"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+const (
+	// wordmarkMaxSize максимальный кегль текстового логотипа в PDF, пт
+	wordmarkMaxSize = 48.0
+	// wordmarkRasterWidth и wordmarkRasterHeight область растра для мокапов, пиксели
+	wordmarkRasterWidth  = 1600
+	wordmarkRasterHeight = 400
+)
+
+// wordmarkColor цвет текстового логотипа: цвет из style_template, если передан, иначе цвет текста шаблона
+func wordmarkColor(style dto.StyleTemplate, t Theme) Color {
+	if style.Color != "" {
+		return t.Palette.Accent
+	}
+	return t.Palette.Text
+}
+
+// drawWordmark пишет текстовый логотип векторным текстом, подбирая кегль под область,
+// и возвращает высоту строки
+func drawWordmark(pdf *gofpdf.Fpdf, text dto.LogoText, family string, c Color, boxW, boxH float64, align string) float64 {
+	style := logoTextStyle(text)
+
+	// Ширина строки линейна по кеглю, меряем на 100 пт
+	pdf.SetFont(family, style, 100)
+	width := pdf.GetStringWidth(text.Value)
+	if width == 0 {
+		return 0
+	}
+	size := math.Min(wordmarkMaxSize, 100*boxW/width)
+	lineHeight := size * 25.4 / 72 * 1.2
+	if lineHeight > boxH {
+		size *= boxH / lineHeight
+		lineHeight = boxH
+	}
+
+	pdf.SetFont(family, style, size)
+	pdf.SetTextColor(c.R, c.G, c.B)
+	pdf.CellFormat(boxW, lineHeight, text.Value, "", 1, align, false, 0, "")
+
+	return lineHeight
+}
+
+// rasterWordmark растеризует текстовый логотип для нанесения на мокап
+func (s *Page) rasterWordmark(text dto.LogoText, c Color) (image.Image, error) {
+	style := strings.ReplaceAll(logoTextStyle(text), "U", "")
+	return wordmark.Render(wordmark.Options{
+		Text:      text.Value,
+		Font:      s.fonts.Family(text.Font).Style(style),
+		Underline: text.Under,
+		Color:     color.RGBA{R: uint8(c.R), G: uint8(c.G), B: uint8(c.B), A: 255},
+		Width:     wordmarkRasterWidth,
+		Height:    wordmarkRasterHeight,
+	})
+}
@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusDead       = "dead"
+)
+
+const (
+	defaultVisibilityTimeout = 10 * time.Minute
+	defaultMaxAttempts       = 5
+	defaultBaseBackoff       = 10 * time.Second
+	defaultMaxBackoff        = 30 * time.Minute
+)
+
+// ErrLeaseLost задачу уже забрал другой воркер: истек таймаут видимости
+var ErrLeaseLost = errors.New("задача перехвачена другим воркером")
+
+// ErrLeaseExpired воркер не завершил задачу за таймаут видимости на последней попытке
+var ErrLeaseExpired = errors.New("истек таймаут видимости на последней попытке")
+
+// Task задача, взятая воркером в работу
+type Task struct {
+	ID          int64
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int
+	MaxAttempts int
+	// Exhausted попытки кончились: воркер, взявший задачу на последней попытке, не завершил ее.
+	// Выполнять такую задачу нельзя, вызывающий переводит ее в Dead с ErrLeaseExpired.
+	Exhausted bool
+}
+
+// Queue очередь задач в таблице queue_task. Несколько реплик сервиса забирают задачи через
+// SELECT ... FOR UPDATE SKIP LOCKED, взятая задача невидима другим до locked_until.
+type Queue struct {
+	db                *sql.DB
+	workerID          string
+	visibilityTimeout time.Duration
+	maxAttempts       int
+	baseBackoff       time.Duration
+	maxBackoff        time.Duration
+}
+
+func New(db *sql.DB, cfg config.QueueConfig) *Queue {
+	hostname, _ := os.Hostname()
+	q := &Queue{
+		db:                db,
+		workerID:          hostname + ":" + strconv.Itoa(os.Getpid()),
+		visibilityTimeout: cfg.VisibilityTimeout,
+		maxAttempts:       cfg.MaxAttempts,
+		baseBackoff:       cfg.BaseBackoff,
+		maxBackoff:        cfg.MaxBackoff,
+	}
+	if q.visibilityTimeout <= 0 {
+		q.visibilityTimeout = defaultVisibilityTimeout
+	}
+	if q.maxAttempts <= 0 {
+		q.maxAttempts = defaultMaxAttempts
+	}
+	if q.baseBackoff <= 0 {
+		q.baseBackoff = defaultBaseBackoff
+	}
+	if q.maxBackoff <= 0 {
+		q.maxBackoff = defaultMaxBackoff
+	}
+
+	return q
+}
+
+// VisibilityTimeout время, на которое взятая задача скрыта от других воркеров
+func (q *Queue) VisibilityTimeout() time.Duration {
+	return q.visibilityTimeout
+}
+
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any) (int64, error) {
+	return q.enqueue(ctx, q.db, "queue.Enqueue", kind, payload)
+}
+
+// EnqueueTx ставит задачу в очередь внутри транзакции tx: задача появится,
+// только если транзакция будет зафиксирована
+func (q *Queue) EnqueueTx(ctx context.Context, tx *sql.Tx, kind string, payload any) (int64, error) {
+	return q.enqueue(ctx, tx, "queue.EnqueueTx", kind, payload)
+}
+
+// queryRower общее у *sql.DB и *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (q *Queue) enqueue(ctx context.Context, db queryRower, op, kind string, payload any) (int64, error) {
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+	INSERT INTO queue_task (kind, payload, status, max_attempts, run_at, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, now(), now(), now())
+	RETURNING id
+	`
+	var id int64
+	if err := db.QueryRowContext(ctx, query, kind, data, StatusPending, q.maxAttempts).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Claim забирает следующую готовую задачу: ожидающую с наступившим run_at или
+// взятую ранее, у которой истек таймаут видимости. Если задач нет, возвращает nil.
+// Задача, у которой таймаут истек на последней попытке, возвращается с Exhausted,
+// чтобы вызывающий перевел ее в dead и отметил сбой у себя.
+func (q *Queue) Claim(ctx context.Context, kind string) (*Task, error) {
+	const op = "queue.Claim"
+	query := `
+	UPDATE queue_task
+	SET status = $2,
+		attempts = attempts + 1,
+		locked_by = $3,
+		locked_until = now() + $4::double precision * interval '1 millisecond',
+		updated_at = now()
+	WHERE id = (
+		SELECT id FROM queue_task
+		WHERE kind = $1
+			AND ((status = $5 AND run_at <= now()) OR (status = $2 AND locked_until < now()))
+		ORDER BY run_at, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING id, kind, payload, attempts, max_attempts
+	`
+
+	var task Task
+	err := q.db.QueryRowContext(ctx, query,
+		kind, StatusProcessing, q.workerID, q.visibilityTimeout.Milliseconds(), StatusPending,
+	).Scan(&task.ID, &task.Kind, &task.Payload, &task.Attempts, &task.MaxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Воркер, взявший задачу, упал столько раз, что попытки кончились
+	task.Exhausted = task.Attempts > task.MaxAttempts
+
+	return &task, nil
+}
+
+// Extend продлевает таймаут видимости задачи, которая еще выполняется
+func (q *Queue) Extend(ctx context.Context, task *Task) error {
+	query := `
+	UPDATE queue_task
+	SET locked_until = now() + $4::double precision * interval '1 millisecond', updated_at = now()
+	WHERE id = $1 AND locked_by = $2 AND attempts = $3 AND status = $5
+	`
+	return q.update(ctx, "queue.Extend", query,
+		task.ID, q.workerID, task.Attempts, q.visibilityTimeout.Milliseconds(), StatusProcessing)
+}
+
+// Complete отмечает задачу выполненной
+func (q *Queue) Complete(ctx context.Context, task *Task) error {
+	query := `
+	UPDATE queue_task
+	SET status = $4, locked_until = NULL, last_error = '', updated_at = now()
+	WHERE id = $1 AND locked_by = $2 AND attempts = $3
+	`
+	return q.update(ctx, "queue.Complete", query, task.ID, q.workerID, task.Attempts, StatusDone)
+}
+
+// Retry возвращает задачу в очередь с экспоненциальной задержкой,
+// после последней попытки задача уходит в dead. Возвращает true, если задача будет повторена.
+func (q *Queue) Retry(ctx context.Context, task *Task, cause error) (bool, error) {
+	if task.Attempts >= task.MaxAttempts {
+		return false, q.Dead(ctx, task, cause)
+	}
+
+	query := `
+	UPDATE queue_task
+	SET status = $4, run_at = now() + $5::double precision * interval '1 millisecond', locked_until = NULL,
+		last_error = $6, updated_at = now()
+	WHERE id = $1 AND locked_by = $2 AND attempts = $3
+	`
+	err := q.update(ctx, "queue.Retry", query,
+		task.ID, q.workerID, task.Attempts, StatusPending, q.Backoff(task.Attempts).Milliseconds(), cause.Error())
+	return err == nil, err
+}
+
+// Dead переводит задачу в dead-letter без дальнейших попыток
+func (q *Queue) Dead(ctx context.Context, task *Task, cause error) error {
+	query := `
+	UPDATE queue_task
+	SET status = $4, locked_until = NULL, last_error = $5, updated_at = now()
+	WHERE id = $1 AND locked_by = $2 AND attempts = $3
+	`
+	return q.update(ctx, "queue.Dead", query, task.ID, q.workerID, task.Attempts, StatusDead, cause.Error())
+}
+
+// Backoff задержка перед повтором: base * 2^(attempt-1), не больше max, с разбросом до 20%
+func (q *Queue) Backoff(attempt int) time.Duration {
+	delay := float64(q.baseBackoff) * math.Pow(2, float64(attempt-1))
+	delay = math.Min(delay, float64(q.maxBackoff))
+	delay *= 1 + rand.Float64()*0.2
+	return time.Duration(delay)
+}
+
+func (q *Queue) update(ctx context.Context, op, query string, args ...any) error {
+	res, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
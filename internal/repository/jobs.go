@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+)
+
+// CreateJob создает задачу и в той же транзакции вызывает enqueue, чтобы задача
+// не осталась в pdf_job без записи в очереди
+func (p *PdfRepository) CreateJob(ctx context.Context, request json.RawMessage, enqueue func(tx *sql.Tx, id int64) error) (int64, error) {
+	const op = "repository.CreateJob"
+	
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания задачи: %s: %v", op, err)
+	}
+	defer tx.Rollback()
+	
+	query := `
+	INSERT INTO pdf_job (status, request, created_at, updated_at)
+	VALUES ($1, $2, now(), now())
+	RETURNING id
+	`
+	var id int64
+	err = tx.QueryRowContext(ctx, query, dto.JobQueued, request).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания задачи: %s: %v", op, err)
+	}
+	
+	if err := enqueue(tx, id); err != nil {
+		return 0, fmt.Errorf("ошибка создания задачи: %s: %v", op, err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка создания задачи: %s: %v", op, err)
+	}
+	
+	return id, nil
+}
+
+func (p *PdfRepository) GetJob(ctx context.Context, id int64) (*dto.Job, error) {
+	const op = "repository.GetJob"
+	query := `
+	SELECT id, status, request, result_key, result_size, result_sha256, error,
+		created_at, updated_at, started_at, finished_at
+	FROM pdf_job
+	WHERE id = $1
+	`
+	var job dto.Job
+	err := p.db.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.Status,
+		&job.Request,
+		&job.Key,
+		&job.Size,
+		&job.SHA256,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения задачи: %s: %v", op, err)
+	}
+	
+	return &job, nil
+}
+
+func (p *PdfRepository) MarkJobRunning(ctx context.Context, id int64) error {
+	const op = "repository.MarkJobRunning"
+	query := `
+	UPDATE pdf_job
+	SET status = $2, started_at = now(), updated_at = now()
+	WHERE id = $1
+	`
+	if _, err := p.db.ExecContext(ctx, query, id, dto.JobRunning); err != nil {
+		return fmt.Errorf("ошибка обновления задачи: %s: %v", op, err)
+	}
+	
+	return nil
+}
+
+// MarkJobQueued возвращает задачу в очередь после неудачной попытки
+func (p *PdfRepository) MarkJobQueued(ctx context.Context, id int64, jobErr string) error {
+	const op = "repository.MarkJobQueued"
+	query := `
+	UPDATE pdf_job
+	SET status = $2, error = $3, updated_at = now()
+	WHERE id = $1
+	`
+	if _, err := p.db.ExecContext(ctx, query, id, dto.JobQueued, jobErr); err != nil {
+		return fmt.Errorf("ошибка обновления задачи: %s: %v", op, err)
+	}
+	
+	return nil
+}
+
+func (p *PdfRepository) MarkJobSucceeded(ctx context.Context, id int64, key string, size int64, sha256 string) error {
+	const op = "repository.MarkJobSucceeded"
+	query := `
+	UPDATE pdf_job
+	SET status = $2, result_key = $3, result_size = $4, result_sha256 = $5, error = '',
+		finished_at = now(), updated_at = now()
+	WHERE id = $1
+	`
+	if _, err := p.db.ExecContext(ctx, query, id, dto.JobSucceeded, key, size, sha256); err != nil {
+		return fmt.Errorf("ошибка обновления задачи: %s: %v", op, err)
+	}
+	
+	return nil
+}
+
+func (p *PdfRepository) MarkJobFailed(ctx context.Context, id int64, jobErr string) error {
+	const op = "repository.MarkJobFailed"
+	query := `
+	UPDATE pdf_job
+	SET status = $2, error = $3, finished_at = now(), updated_at = now()
+	WHERE id = $1
+	`
+	if _, err := p.db.ExecContext(ctx, query, id, dto.JobFailed, jobErr); err != nil {
+		return fmt.Errorf("ошибка обновления задачи: %s: %v", op, err)
+	}
+	
+	return nil
+}
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var (
+	ErrInvalidCursor   = errors.New("невалидный курсор")
+	ErrVersionConflict = errors.New("запись изменена другим запросом")
+)
+
+const pdfColumns = `
+	id, id_user, id_cart, id_publication, publication_url,
+	logo, executor_parameters, presentation_parameters, style_template,
+	count, save_required, version, created_at, updated_at
+	`
+
+func (p *PdfRepository) GetByID(ctx context.Context, id int64) (*dto.SavedPdf, error) {
+	const op = "repository.GetByID"
+	query := `SELECT ` + pdfColumns + ` FROM pdf_kp WHERE id = $1 AND deleted_at IS NULL`
+	
+	pdf, err := scanPdf(p.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения записи: %s: %v", op, err)
+	}
+	
+	return pdf, nil
+}
+
+// ListByUser КП пользователя от новых к старым, filter.CartId дополнительно сужает выборку
+func (p *PdfRepository) ListByUser(ctx context.Context, userId int64, filter dto.PdfFilter) (*dto.SavedPdfPage, error) {
+	filter.UserId = userId
+	return p.list(ctx, "repository.ListByUser", filter)
+}
+
+// ListByCart КП по корзине от новых к старым
+func (p *PdfRepository) ListByCart(ctx context.Context, cartId int64, filter dto.PdfFilter) (*dto.SavedPdfPage, error) {
+	filter.CartId = cartId
+	return p.list(ctx, "repository.ListByCart", filter)
+}
+
+// list выбирает страницу по ключу (created_at, id): в отличие от OFFSET вставка новых
+// записей не сдвигает уже выданные страницы
+func (p *PdfRepository) list(ctx context.Context, op string, filter dto.PdfFilter) (*dto.SavedPdfPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+	
+	where := []string{"deleted_at IS NULL"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.UserId != 0 {
+		add("id_user = $%d", filter.UserId)
+	}
+	if filter.CartId != 0 {
+		add("id_cart = $%d", filter.CartId)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, createdAt, id)
+		where = append(where, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	
+	query := `SELECT ` + pdfColumns + ` FROM pdf_kp WHERE ` + strings.Join(where, " AND ")
+	// Берем на одну запись больше, чтобы понять, есть ли следующая страница
+	args = append(args, limit+1)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+	
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения записей: %s: %v", op, err)
+	}
+	defer rows.Close()
+	
+	page := &dto.SavedPdfPage{Items: []dto.SavedPdf{}}
+	for rows.Next() {
+		pdf, err := scanPdf(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения записей: %s: %v", op, err)
+		}
+		page.Items = append(page.Items, *pdf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка получения записей: %s: %v", op, err)
+	}
+	
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	
+	return page, nil
+}
+
+// Update заменяет изменяемые поля КП, если его версия все еще равна version.
+// Изменение помечает КП как требующее пересохранения PDF.
+func (p *PdfRepository) Update(ctx context.Context, id int64, version int, update dto.PdfUpdate) (*dto.SavedPdf, error) {
+	const op = "repository.Update"
+	
+	logo, err := json.Marshal(update.Logo)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	executorParams, err := json.Marshal(update.ExecutorParameters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	presentationParams, err := json.Marshal(update.PresentationParameters)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	styleTemplate, err := json.Marshal(update.StyleTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	
+	query := `
+	UPDATE pdf_kp
+	SET logo = $3,
+		executor_parameters = $4,
+		presentation_parameters = $5,
+		style_template = $6,
+		count = $7,
+		save_required = true,
+		version = version + 1,
+		updated_at = now()
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	RETURNING ` + pdfColumns
+	
+	pdf, err := scanPdf(p.db.QueryRowContext(ctx, query,
+		id, version, logo, executorParams, presentationParams, styleTemplate, update.Count))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, p.versionMismatch(ctx, op, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления записи: %s: %v", op, err)
+	}
+	
+	return pdf, nil
+}
+
+// Delete помечает КП удаленным. version == 0 - без проверки версии
+func (p *PdfRepository) Delete(ctx context.Context, id int64, version int) error {
+	const op = "repository.Delete"
+	query := `
+	UPDATE pdf_kp
+	SET deleted_at = now(), version = version + 1, updated_at = now()
+	WHERE id = $1 AND ($2 = 0 OR version = $2) AND deleted_at IS NULL
+	`
+	res, err := p.db.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления записи: %s: %v", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка удаления записи: %s: %v", op, err)
+	}
+	if n == 0 {
+		return p.versionMismatch(ctx, op, id)
+	}
+	
+	return nil
+}
+
+// versionMismatch отличает отсутствующую запись от устаревшей версии, когда условный UPDATE ничего не изменил
+func (p *PdfRepository) versionMismatch(ctx context.Context, op string, id int64) error {
+	var exists bool
+	err := p.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pdf_kp WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки записи: %s: %v", op, err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPdf читает строку pdf_kp и раскладывает JSONB-колонки обратно в поля запроса
+func scanPdf(row scanner) (*dto.SavedPdf, error) {
+	var (
+		pdf                                                     dto.SavedPdf
+		userId, cartId, publicationId, count                    sql.NullInt64
+		publicationURL                                          sql.NullString
+		saveRequired                                            sql.NullBool
+		createdAt, updatedAt                                    sql.NullTime
+		logo, executorParams, presentationParams, styleTemplate []byte
+	)
+	err := row.Scan(
+		&pdf.ID,
+		&userId,
+		&cartId,
+		&publicationId,
+		&publicationURL,
+		&logo,
+		&executorParams,
+		&presentationParams,
+		&styleTemplate,
+		&count,
+		&saveRequired,
+		&pdf.Version,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	
+	pdf.UserId = userId.Int64
+	pdf.CartId = cartId.Int64
+	pdf.PublicationId = publicationId.Int64
+	pdf.PublicationURL = publicationURL.String
+	pdf.Count = int(count.Int64)
+	pdf.SaveRequired = saveRequired.Bool
+	pdf.CreatedAt = createdAt.Time
+	pdf.UpdatedAt = updatedAt.Time
+	
+	for _, col := range []struct {
+		name string
+		data []byte
+		dst  any
+	}{
+		{"logo", logo, &pdf.Logo},
+		{"executor_parameters", executorParams, &pdf.ExecutorParameters},
+		{"presentation_parameters", presentationParams, &pdf.PresentationParameters},
+		{"style_template", styleTemplate, &pdf.StyleTemplate},
+	} {
+		if len(col.data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(col.data, col.dst); err != nil {
+			return nil, fmt.Errorf("ошибка разбора %s записи %d: %w", col.name, pdf.ID, err)
+		}
+	}
+	
+	return &pdf, nil
+}
+
+// Курсор непрозрачен для клиента: base64 от "<created_at в наносекундах>:<id>"
+func encodeCursor(createdAt time.Time, id int64) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	pdfId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, nanos).UTC(), pdfId, nil
+}
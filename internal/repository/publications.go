@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"time"
+)
+
+// CreatePublication сохраняет публикацию и проставляет ее id в pdf_kp.id_publication
+func (p *PdfRepository) CreatePublication(ctx context.Context, pdfId int64, slug string, expiresAt *time.Time) (*dto.Publication, error) {
+	const op = "repository.CreatePublication"
+	
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания публикации: %s: %v", op, err)
+	}
+	defer tx.Rollback()
+	
+	pub := dto.Publication{PdfID: pdfId, Slug: slug, ExpiresAt: expiresAt}
+	query := `
+	INSERT INTO publication (pdf_kp_id, slug, expires_at, created_at)
+	SELECT $1, $2, $3, now()
+	FROM pdf_kp WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query, pdfId, slug, nullTime(expiresAt)).Scan(&pub.ID, &pub.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания публикации: %s: %v", op, err)
+	}
+	
+	query = `
+	UPDATE pdf_kp SET id_publication = $2, version = version + 1, updated_at = now()
+	WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, pdfId, pub.ID); err != nil {
+		return nil, fmt.Errorf("ошибка создания публикации: %s: %v", op, err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка создания публикации: %s: %v", op, err)
+	}
+	
+	return &pub, nil
+}
+
+// GetPublication публикация по slug. Публикация удаленного КП не возвращается
+func (p *PdfRepository) GetPublication(ctx context.Context, slug string) (*dto.Publication, error) {
+	const op = "repository.GetPublication"
+	query := `
+	SELECT pub.id, pub.pdf_kp_id, pub.slug, pub.expires_at, pub.revoked, pub.revoked_at, pub.created_at
+	FROM publication pub
+	JOIN pdf_kp kp ON kp.id = pub.pdf_kp_id
+	WHERE pub.slug = $1 AND kp.deleted_at IS NULL
+	`
+	var pub dto.Publication
+	var expiresAt, revokedAt sql.NullTime
+	err := p.db.QueryRowContext(ctx, query, slug).Scan(
+		&pub.ID, &pub.PdfID, &pub.Slug, &expiresAt, &pub.Revoked, &revokedAt, &pub.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения публикации: %s: %v", op, err)
+	}
+	pub.ExpiresAt = timePtr(expiresAt)
+	pub.RevokedAt = timePtr(revokedAt)
+	
+	return &pub, nil
+}
+
+func (p *PdfRepository) RevokePublication(ctx context.Context, slug string) error {
+	const op = "repository.RevokePublication"
+	query := `
+	UPDATE publication SET revoked = true, revoked_at = COALESCE(revoked_at, now())
+	WHERE slug = $1
+	`
+	res, err := p.db.ExecContext(ctx, query, slug)
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва публикации: %s: %v", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка отзыва публикации: %s: %v", op, err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	
+	return nil
+}
+
+// LatestRevision последняя ревизия КП без входных данных
+func (p *PdfRepository) LatestRevision(ctx context.Context, pdfId int64) (*dto.Revision, error) {
+	const op = "repository.LatestRevision"
+	query := `
+	SELECT id, pdf_kp_id, number, key, sha256, size, pages, template_id, created_at
+	FROM pdf_kp_revision
+	WHERE pdf_kp_id = $1
+	ORDER BY number DESC
+	LIMIT 1
+	`
+	var rev dto.Revision
+	err := p.db.QueryRowContext(ctx, query, pdfId).Scan(
+		&rev.ID, &rev.PdfID, &rev.Number, &rev.Key, &rev.SHA256, &rev.Size, &rev.Pages, &rev.TemplateID, &rev.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ревизии: %s: %v", op, err)
+	}
+	
+	return &rev, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -4,42 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	_ "github.com/lib/pq"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
 )
 
+var ErrNotFound = errors.New("запись не найдена")
+
 type PdfRepository struct {
 	db *sql.DB
 }
 
-func New(db *sql.DB) (*PdfRepository, error) {
-	repo := &PdfRepository{db: db}
-	if err := repo.CreateTable(); err != nil {
-		return nil, fmt.Errorf("failed to create table")
-	}
-	return repo, nil
-}
-
-func (p *PdfRepository) CreateTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS pdf_kp(
-		id BIGSERIAL PRIMARY KEY,
-		id_user BIGINT,
-		id_cart BIGINT,
-		id_publication BIGINT,
-		publication_url TEXT,
-		logo JSONB,
-		executor_parameters JSONB,
-		presentation_parameters JSONB,
-		style_template JSONB,
-		count INT,
-		save_required BOOLEAN,
-		created_at TIMESTAMP,
-		updated_at TIMESTAMP
-	    );
-	`
-	_, err := p.db.Exec(query)
-	return err
+// New схема создается миграциями из internal/migrate
+func New(db *sql.DB) *PdfRepository {
+	return &PdfRepository{db: db}
 }
 
 func (p *PdfRepository) Save(
@@ -52,7 +31,7 @@ func (p *PdfRepository) Save(
 	presentationParameters json.RawMessage,
 	styleTemplate json.RawMessage,
 	count int,
-) error {
+) (int64, error) {
 	const op = "repository.Save"
 	query := `
 	INSERT INTO pdf_kp (
@@ -70,11 +49,76 @@ func (p *PdfRepository) Save(
 	) VALUES (
 	    $1, $2, $3, $4, $5, $6, $7, $8, true, now(), now()
 	)
+	RETURNING id
+	`
+	var id int64
+	err := p.db.QueryRowContext(ctx, query, userId, cartId, publicationId, logo, executorParameters, presentationParameters, styleTemplate, count).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка добавления записи: %s: %v", op, err)
+	}
+	
+	return id, nil
+}
+
+func (p *PdfRepository) SaveExecutor(ctx context.Context, executor dto.Executor) error {
+	const op = "repository.SaveExecutor"
+	query := `
+	INSERT INTO executor (
+		id_user,
+		company_name,
+		phone,
+		email,
+		logo,
+		created_at,
+		updated_at
+	) VALUES (
+		$1, $2, $3, $4, $5, now(), now()
+	)
+	ON CONFLICT (id_user) DO UPDATE SET
+		company_name = EXCLUDED.company_name,
+		phone = EXCLUDED.phone,
+		email = EXCLUDED.email,
+		logo = EXCLUDED.logo,
+		updated_at = now()
 	`
-	_, err := p.db.ExecContext(ctx, query, userId, cartId, publicationId, logo, executorParameters, presentationParameters, styleTemplate, count)
+	_, err := p.db.ExecContext(ctx, query, executor.UserId, executor.CompanyName, executor.Phone, executor.Email, executor.Logo)
 	if err != nil {
-		return fmt.Errorf("ошибка добавления записи: %s: %v", op, err)
+		return fmt.Errorf("ошибка сохранения исполнителя: %s: %v", op, err)
 	}
 	
 	return nil
 }
+
+func (p *PdfRepository) GetExecutor(ctx context.Context, userId int64) (*dto.Executor, error) {
+	const op = "repository.GetExecutor"
+	query := `
+	SELECT id_user, company_name, phone, email, logo
+	FROM executor
+	WHERE id_user = $1
+	`
+	var executor dto.Executor
+	err := p.db.QueryRowContext(ctx, query, userId).Scan(
+		&executor.UserId,
+		&executor.CompanyName,
+		&executor.Phone,
+		&executor.Email,
+		&executor.Logo,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения исполнителя: %s: %v", op, err)
+	}
+	
+	return &executor, nil
+}
+
+// FindExecutor как GetExecutor, но отсутствие профиля не ошибка: возвращает nil, nil
+func (p *PdfRepository) FindExecutor(ctx context.Context, userId int64) (*dto.Executor, error) {
+	executor, err := p.GetExecutor(ctx, userId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return executor, err
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReferencedKeys ключи объектов, на которые ссылаются данные сервиса и которые нельзя удалять:
+// текущий документ каждого неудаленного КП, последние keepPerCart ревизий каждой корзины
+// (keepPerCart <= 0 - все ревизии) и результаты задач, обновленных за последние jobsWithin.
+func (p *PdfRepository) ReferencedKeys(ctx context.Context, keepPerCart int, jobsWithin time.Duration) (map[string]struct{}, error) {
+	const op = "repository.ReferencedKeys"
+	query := `
+	SELECT publication_url FROM pdf_kp
+	WHERE deleted_at IS NULL AND COALESCE(publication_url, '') <> ''
+	UNION
+	SELECT key FROM (
+		SELECT r.key, row_number() OVER (PARTITION BY k.id_cart ORDER BY r.created_at DESC, r.id DESC) AS n
+		FROM pdf_kp_revision r
+		JOIN pdf_kp k ON k.id = r.pdf_kp_id
+		WHERE k.deleted_at IS NULL
+	) revisions
+	WHERE $1::int <= 0 OR n <= $1::int
+	UNION
+	SELECT result_key FROM pdf_job
+	WHERE result_key <> '' AND updated_at > now() - $2::double precision * interval '1 millisecond'
+	`
+	rows, err := p.db.QueryContext(ctx, query, keepPerCart, jobsWithin.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ключей: %s: %v", op, err)
+	}
+	defer rows.Close()
+	
+	keys := make(map[string]struct{})
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("ошибка получения ключей: %s: %v", op, err)
+		}
+		keys[key] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка получения ключей: %s: %v", op, err)
+	}
+	
+	return keys, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+)
+
+// AddRevision записывает сгенерированный документ как новую ревизию КП, обновляет
+// publication_url и снимает save_required. Если после чтения конфигурации КП успели
+// изменить, документ устарел и не записывается.
+func (p *PdfRepository) AddRevision(ctx context.Context, version int, rev dto.Revision) (*dto.SavedPdf, *dto.Revision, error) {
+	const op = "repository.AddRevision"
+	
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка добавления ревизии: %s: %v", op, err)
+	}
+	defer tx.Rollback()
+	
+	// UPDATE блокирует строку КП, поэтому номера ревизий не пересекаются
+	query := `
+	UPDATE pdf_kp
+	SET publication_url = $3, save_required = false, version = version + 1, updated_at = now()
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	RETURNING ` + pdfColumns
+	pdf, err := scanPdf(tx.QueryRowContext(ctx, query, rev.PdfID, version, rev.Key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, p.versionMismatch(ctx, op, rev.PdfID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка добавления ревизии: %s: %v", op, err)
+	}
+	
+	query = `
+	INSERT INTO pdf_kp_revision (pdf_kp_id, number, key, sha256, size, pages, template_id, input, created_at)
+	SELECT $1, COALESCE(MAX(number), 0) + 1, $2, $3, $4, $5, $6, $7, now()
+	FROM pdf_kp_revision WHERE pdf_kp_id = $1
+	RETURNING id, number, created_at
+	`
+	err = tx.QueryRowContext(ctx, query, rev.PdfID, rev.Key, rev.SHA256, rev.Size, rev.Pages, rev.TemplateID, []byte(rev.Input)).
+		Scan(&rev.ID, &rev.Number, &rev.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка добавления ревизии: %s: %v", op, err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("ошибка добавления ревизии: %s: %v", op, err)
+	}
+	
+	return pdf, &rev, nil
+}
+
+// ListRevisions ревизии КП от новых к старым, без входных данных
+func (p *PdfRepository) ListRevisions(ctx context.Context, pdfId int64) ([]dto.Revision, error) {
+	const op = "repository.ListRevisions"
+	query := `
+	SELECT id, pdf_kp_id, number, key, sha256, size, pages, template_id, created_at
+	FROM pdf_kp_revision
+	WHERE pdf_kp_id = $1
+	ORDER BY number DESC
+	`
+	rows, err := p.db.QueryContext(ctx, query, pdfId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ревизий: %s: %v", op, err)
+	}
+	defer rows.Close()
+	
+	revisions := []dto.Revision{}
+	for rows.Next() {
+		var rev dto.Revision
+		err := rows.Scan(&rev.ID, &rev.PdfID, &rev.Number, &rev.Key, &rev.SHA256, &rev.Size, &rev.Pages, &rev.TemplateID, &rev.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения ревизий: %s: %v", op, err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка получения ревизий: %s: %v", op, err)
+	}
+	
+	return revisions, nil
+}
+
+// GetRevision ревизия КП по номеру вместе с входными данными
+func (p *PdfRepository) GetRevision(ctx context.Context, pdfId int64, number int) (*dto.Revision, error) {
+	const op = "repository.GetRevision"
+	query := `
+	SELECT id, pdf_kp_id, number, key, sha256, size, pages, template_id, input, created_at
+	FROM pdf_kp_revision
+	WHERE pdf_kp_id = $1 AND number = $2
+	`
+	var rev dto.Revision
+	var input []byte
+	err := p.db.QueryRowContext(ctx, query, pdfId, number).Scan(
+		&rev.ID, &rev.PdfID, &rev.Number, &rev.Key, &rev.SHA256, &rev.Size, &rev.Pages, &rev.TemplateID, &input, &rev.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ревизии: %s: %v", op, err)
+	}
+	rev.Input = input
+	
+	return &rev, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+)
+
+// RecordView записывает открытие публикации. Возвращает true, если это первое открытие ссылки
+func (p *PdfRepository) RecordView(ctx context.Context, pub *dto.Publication, view dto.View) (bool, error) {
+	const op = "repository.RecordView"
+	
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("ошибка записи просмотра: %s: %v", op, err)
+	}
+	defer tx.Rollback()
+	
+	query := `
+	INSERT INTO publication_view (publication_id, pdf_kp_id, ip_hash, user_agent, referer, viewed_at)
+	VALUES ($1, $2, $3, $4, $5, now())
+	`
+	if _, err := tx.ExecContext(ctx, query, pub.ID, pub.PdfID, view.IPHash, view.UserAgent, view.Referer); err != nil {
+		return false, fmt.Errorf("ошибка записи просмотра: %s: %v", op, err)
+	}
+	
+	// Условный UPDATE срабатывает ровно у одного из одновременных первых просмотров
+	res, err := tx.ExecContext(ctx, `UPDATE publication SET first_viewed_at = now() WHERE id = $1 AND first_viewed_at IS NULL`, pub.ID)
+	if err != nil {
+		return false, fmt.Errorf("ошибка записи просмотра: %s: %v", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("ошибка записи просмотра: %s: %v", op, err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("ошибка записи просмотра: %s: %v", op, err)
+	}
+	
+	return n == 1, nil
+}
+
+// ViewStats агрегирует просмотры КП по всем публикациям
+func (p *PdfRepository) ViewStats(ctx context.Context, pdfId int64) (*dto.ViewStats, error) {
+	const op = "repository.ViewStats"
+	stats := dto.ViewStats{PdfID: pdfId, Publications: []dto.PublicationStats{}}
+	
+	var first, last sql.NullTime
+	query := `
+	SELECT count(*), count(DISTINCT ip_hash), min(viewed_at), max(viewed_at)
+	FROM publication_view
+	WHERE pdf_kp_id = $1
+	`
+	err := p.db.QueryRowContext(ctx, query, pdfId).Scan(&stats.Views, &stats.UniqueViewers, &first, &last)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики: %s: %v", op, err)
+	}
+	stats.FirstViewedAt = timePtr(first)
+	stats.LastViewedAt = timePtr(last)
+	
+	query = `
+	SELECT pub.slug, count(v.id), min(v.viewed_at), max(v.viewed_at)
+	FROM publication pub
+	LEFT JOIN publication_view v ON v.publication_id = pub.id
+	WHERE pub.pdf_kp_id = $1
+	GROUP BY pub.id, pub.slug
+	ORDER BY pub.id
+	`
+	rows, err := p.db.QueryContext(ctx, query, pdfId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики: %s: %v", op, err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var ps dto.PublicationStats
+		if err := rows.Scan(&ps.Slug, &ps.Views, &first, &last); err != nil {
+			return nil, fmt.Errorf("ошибка получения статистики: %s: %v", op, err)
+		}
+		ps.FirstViewedAt = timePtr(first)
+		ps.LastViewedAt = timePtr(last)
+		stats.Publications = append(stats.Publications, ps)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка получения статистики: %s: %v", op, err)
+	}
+	
+	return &stats, nil
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+)
+
+// SaveWebhookDelivery пишет попытку отправки уведомления в журнал
+func (p *PdfRepository) SaveWebhookDelivery(ctx context.Context, d dto.WebhookDelivery) error {
+	const op = "repository.SaveWebhookDelivery"
+	query := `
+	INSERT INTO webhook_delivery (task_id, job_id, event, url, attempt, status_code, error, duration_ms, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+	`
+	jobID := sql.NullInt64{Int64: d.JobID, Valid: d.JobID != 0}
+	_, err := p.db.ExecContext(ctx, query,
+		d.TaskID, jobID, d.Event, d.URL, d.Attempt, d.StatusCode, d.Error, d.Duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("ошибка записи доставки уведомления: %s: %v", op, err)
+	}
+	
+	return nil
+}
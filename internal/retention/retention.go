@@ -0,0 +1,157 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval = 6 * time.Hour
+	defaultMaxAge   = 30 * 24 * time.Hour
+)
+
+// ReferenceSource ключи объектов, которые нельзя удалять, реализуется *repository.PdfRepository.
+// keepPerCart последних ревизий каждой корзины (<= 0 - все), результаты задач за последние jobsWithin
+type ReferenceSource interface {
+	ReferencedKeys(ctx context.Context, keepPerCart int, jobsWithin time.Duration) (map[string]struct{}, error)
+}
+
+// Report результат одного прохода очистки
+type Report struct {
+	DryRun     bool
+	Scanned    int
+	Referenced int
+	Removed    []storage.Object // удаленные объекты, в dry-run - которые были бы удалены
+	Bytes      int64
+}
+
+// Sweeper периодически удаляет из upload_dir объекты, на которые не ссылаются КП, ревизии
+// и недавние задачи. Проход на нескольких репликах сразу безопасен: удаление идемпотентно.
+type Sweeper struct {
+	refs        ReferenceSource
+	store       storage.Store
+	logger      *zap.Logger
+	prefix      string
+	interval    time.Duration
+	keepPerCart int
+	maxAge      time.Duration
+	dryRun      bool
+	now         func() time.Time
+	wg          sync.WaitGroup
+}
+
+// New создает очистку объектов под uploadDir. Пустой uploadDir - ошибка: иначе очистка
+// прошлась бы по всему хранилищу, включая загруженные картинки
+func New(refs ReferenceSource, store storage.Store, logger *zap.Logger, uploadDir string, cfg config.RetentionConfig) (*Sweeper, error) {
+	dir := strings.TrimRight(uploadDir, "/")
+	if dir == "" {
+		return nil, errors.New("не задан upload_dir для очистки PDF")
+	}
+	s := &Sweeper{
+		refs:        refs,
+		store:       store,
+		logger:      logger,
+		prefix:      dir + "/",
+		interval:    cfg.Interval,
+		keepPerCart: cfg.KeepPerCart,
+		maxAge:      cfg.MaxAge,
+		dryRun:      cfg.DryRun,
+		now:         time.Now,
+	}
+	if s.interval <= 0 {
+		s.interval = defaultInterval
+	}
+	if s.maxAge <= 0 {
+		s.maxAge = defaultMaxAge
+	}
+
+	return s, nil
+}
+
+// Start запускает периодическую очистку до отмены ctx, Wait дожидается завершения прохода
+func (s *Sweeper) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.work(ctx)
+}
+
+func (s *Sweeper) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Sweeper) work(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.Sweep(ctx, s.dryRun)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("ошибка очистки PDF", zap.Error(err))
+		}
+		if report != nil {
+			s.logger.Info("очистка PDF завершена",
+				zap.Bool("dry_run", report.DryRun),
+				zap.Int("scanned", report.Scanned),
+				zap.Int("removed", len(report.Removed)),
+				zap.Int64("bytes", report.Bytes))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep выполняет один проход: удаляет объекты под upload_dir без ссылок и старше max_age.
+// При dryRun ничего не удаляет, только возвращает и пишет в лог кандидатов.
+func (s *Sweeper) Sweep(ctx context.Context, dryRun bool) (*Report, error) {
+	// Сначала список, потом ссылки: ревизия на объект, загруженный после листинга, в список
+	// не попадет, а ссылка, записанная после запроса ссылок, защищена возрастом объекта
+	objects, err := s.store.List(ctx, s.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка очистки PDF: %w", err)
+	}
+	referenced, err := s.refs.ReferencedKeys(ctx, s.keepPerCart, s.maxAge)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка очистки PDF: %w", err)
+	}
+
+	report := &Report{DryRun: dryRun, Scanned: len(objects), Removed: []storage.Object{}}
+	cutoff := s.now().Add(-s.maxAge)
+	for _, obj := range objects {
+		if _, ok := referenced[obj.Key]; ok {
+			report.Referenced++
+			continue
+		}
+		// Свежие объекты еще могут получить ссылку: генерация загружает PDF до записи ревизии.
+		// Старый объект, только что переиспользованный дедупликацией, может быть удален до
+		// записи ревизии - следующая генерация с теми же данными загрузит его заново
+		if obj.ModTime.After(cutoff) {
+			continue
+		}
+
+		logger := s.logger.With(zap.String("key", obj.Key), zap.Time("modified", obj.ModTime), zap.Int64("size", obj.Size))
+		if dryRun {
+			logger.Info("PDF был бы удален")
+		} else {
+			if err := s.store.Delete(ctx, obj.Key); err != nil {
+				return report, fmt.Errorf("ошибка очистки PDF: %w", err)
+			}
+			logger.Info("PDF удален")
+		}
+		report.Removed = append(report.Removed, obj)
+		report.Bytes += obj.Size
+	}
+
+	return report, nil
+}
@@ -0,0 +1,170 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"go.uber.org/zap"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMaxAge = 30 * 24 * time.Hour
+
+// fakeRefs повторяет выборку repository.ReferencedKeys: текущие документы КП
+// и последние keepPerCart ревизий каждой корзины
+type fakeRefs struct {
+	current   []string
+	revisions map[int64][]string // ревизии корзины от новых к старым
+	keep      int
+}
+
+func (f *fakeRefs) ReferencedKeys(ctx context.Context, keepPerCart int, jobsWithin time.Duration) (map[string]struct{}, error) {
+	f.keep = keepPerCart
+	keys := make(map[string]struct{})
+	for _, key := range f.current {
+		keys[key] = struct{}{}
+	}
+	for _, revs := range f.revisions {
+		if keepPerCart > 0 && len(revs) > keepPerCart {
+			revs = revs[:keepPerCart]
+		}
+		for _, key := range revs {
+			keys[key] = struct{}{}
+		}
+	}
+	return keys, nil
+}
+
+func newTestSweeper(t *testing.T, keepPerCart int, age time.Duration) (*Sweeper, *storage.Memory, *fakeRefs) {
+	t.Helper()
+	store := storage.NewMemory()
+	for _, key := range []string{
+		"pdfs/current.pdf",
+		"pdfs/cart1-r1.pdf",
+		"pdfs/cart1-r2.pdf",
+		"pdfs/cart1-r3.pdf",
+		"pdfs/cart2-r1.pdf",
+		"pdfs/orphan.pdf",
+		"uploads/logo.png",
+	} {
+		if err := store.Put(context.Background(), key, strings.NewReader(key), storage.PutOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs := &fakeRefs{
+		current: []string{"pdfs/current.pdf"},
+		revisions: map[int64][]string{
+			1: {"pdfs/cart1-r3.pdf", "pdfs/cart1-r2.pdf", "pdfs/cart1-r1.pdf"},
+			2: {"pdfs/cart2-r1.pdf"},
+		},
+	}
+	s, err := New(refs, store, zap.NewNop(), "pdfs/", config.RetentionConfig{KeepPerCart: keepPerCart, MaxAge: testMaxAge})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Объекты только что загружены, поэтому их возраст задается сдвигом часов очистки
+	s.now = func() time.Time { return time.Now().Add(age) }
+	return s, store, refs
+}
+
+func removedKeys(report *Report) []string {
+	keys := []string{}
+	for _, obj := range report.Removed {
+		keys = append(keys, obj.Key)
+	}
+	return keys
+}
+
+func TestSweepKeepPerCart(t *testing.T) {
+	tests := []struct {
+		keep int
+		want []string
+	}{
+		{0, []string{"pdfs/orphan.pdf"}},
+		{1, []string{"pdfs/cart1-r1.pdf", "pdfs/cart1-r2.pdf", "pdfs/orphan.pdf"}},
+		{2, []string{"pdfs/cart1-r1.pdf", "pdfs/orphan.pdf"}},
+	}
+	for _, tt := range tests {
+		s, store, refs := newTestSweeper(t, tt.keep, testMaxAge+time.Hour)
+		report, err := s.Sweep(context.Background(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refs.keep != tt.keep {
+			t.Errorf("keep_per_cart %d: ReferencedKeys получил %d", tt.keep, refs.keep)
+		}
+		if got := removedKeys(report); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("keep_per_cart %d: удалены %v, want %v", tt.keep, got, tt.want)
+		}
+		if report.Scanned != 6 || report.Referenced != 6-len(tt.want) {
+			t.Errorf("keep_per_cart %d: scanned %d, referenced %d", tt.keep, report.Scanned, report.Referenced)
+		}
+		for _, key := range tt.want {
+			if _, err := store.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+				t.Errorf("keep_per_cart %d: %s не удален: %v", tt.keep, key, err)
+			}
+		}
+		// Объекты вне upload_dir очистка не трогает
+		if _, err := store.Stat(context.Background(), "uploads/logo.png"); err != nil {
+			t.Errorf("keep_per_cart %d: uploads/logo.png: %v", tt.keep, err)
+		}
+	}
+}
+
+func TestSweepMaxAge(t *testing.T) {
+	tests := []struct {
+		name string
+		age  time.Duration
+		want []string
+	}{
+		{"fresh", 0, []string{}},
+		{"younger", testMaxAge - time.Hour, []string{}},
+		{"older", testMaxAge + time.Hour, []string{"pdfs/cart1-r1.pdf", "pdfs/orphan.pdf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestSweeper(t, 2, tt.age)
+			report, err := s.Sweep(context.Background(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := removedKeys(report); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("удалены %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSweepDryRun(t *testing.T) {
+	s, store, _ := newTestSweeper(t, 2, testMaxAge+time.Hour)
+	report, err := s.Sweep(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"pdfs/cart1-r1.pdf", "pdfs/orphan.pdf"}
+	if got := removedKeys(report); !report.DryRun || !reflect.DeepEqual(got, want) {
+		t.Errorf("dry-run %v, кандидаты %v, want %v", report.DryRun, got, want)
+	}
+	if want := int64(len("pdfs/cart1-r1.pdf") + len("pdfs/orphan.pdf")); report.Bytes != want {
+		t.Errorf("bytes %d, want %d", report.Bytes, want)
+	}
+	objects, err := store.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 7 {
+		t.Errorf("в dry-run удалены объекты, осталось %d", len(objects))
+	}
+}
+
+func TestNewRequiresUploadDir(t *testing.T) {
+	for _, dir := range []string{"", "/"} {
+		if _, err := New(&fakeRefs{}, storage.NewMemory(), zap.NewNop(), dir, config.RetentionConfig{}); err == nil {
+			t.Errorf("New(upload_dir %q) должен вернуть ошибку", dir)
+		}
+	}
+}
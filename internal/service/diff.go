@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diffJSON перечисляет отличия b от a. Объекты сравниваются по ключам,
+// массивы - поэлементно по индексу
+func diffJSON(a, b json.RawMessage) ([]dto.RevisionChange, error) {
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return nil, err
+	}
+
+	changes := []dto.RevisionChange{}
+	diffValue("", va, vb, &changes)
+	return changes, nil
+}
+
+func diffValue(path string, a, b any, changes *[]dto.RevisionChange) {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(av)+len(bv))
+			for k := range av {
+				keys = append(keys, k)
+			}
+			for k := range bv {
+				if _, ok := av[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				child := path + "/" + escapePointer(k)
+				aChild, inA := av[k]
+				bChild, inB := bv[k]
+				switch {
+				case !inA:
+					*changes = append(*changes, dto.RevisionChange{Op: "add", Path: child, To: bChild})
+				case !inB:
+					*changes = append(*changes, dto.RevisionChange{Op: "remove", Path: child, From: aChild})
+				default:
+					diffValue(child, aChild, bChild, changes)
+				}
+			}
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			for i := 0; i < max(len(av), len(bv)); i++ {
+				child := path + "/" + strconv.Itoa(i)
+				switch {
+				case i >= len(av):
+					*changes = append(*changes, dto.RevisionChange{Op: "add", Path: child, To: bv[i]})
+				case i >= len(bv):
+					*changes = append(*changes, dto.RevisionChange{Op: "remove", Path: child, From: av[i]})
+				default:
+					diffValue(child, av[i], bv[i], changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, dto.RevisionChange{Op: "replace", Path: path, From: a, To: b})
+	}
+}
+
+// escapePointer экранирует ключ для JSON Pointer (RFC 6901)
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
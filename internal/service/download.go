@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDownloadTTL = 15 * time.Minute
+	// maxDownloadTTL presigned URL S3 (SigV4) не живет дольше недели
+	maxDownloadTTL = 7 * 24 * time.Hour
+)
+
+var ErrInvalidTTL = errors.New("невалидный ttl ссылки")
+
+// DownloadURL временная ссылка на скачивание последней ревизии КП.
+// ttl 0 - время жизни по умолчанию из конфига
+func (s *PdfService) DownloadURL(ctx context.Context, pdfId int64, ttl time.Duration) (*dto.DownloadURL, error) {
+	maxTTL := s.download.MaxTTL
+	if maxTTL <= 0 || maxTTL > maxDownloadTTL {
+		maxTTL = maxDownloadTTL
+	}
+	if ttl == 0 {
+		ttl = s.download.DefaultTTL
+		if ttl <= 0 {
+			ttl = defaultDownloadTTL
+		}
+		ttl = min(ttl, maxTTL)
+	}
+	if ttl < time.Second || ttl > maxTTL {
+		return nil, fmt.Errorf("%w: допустимо от 1s до %s", ErrInvalidTTL, maxTTL)
+	}
+
+	saved, err := s.pdfRepo.GetByID(ctx, pdfId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании ссылки на КП: %w", err)
+	}
+	rev, err := s.pdfRepo.LatestRevision(ctx, pdfId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании ссылки на КП: %w", err)
+	}
+
+	date := rev.CreatedAt.Format(time.DateOnly)
+	filename := fmt.Sprintf("КП_%d_%s.pdf", saved.CartId, date)
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	url, err := s.store.PresignGet(ctx, rev.Key, ttl, storage.PresignOptions{
+		ContentDisposition: contentDisposition(filename, fmt.Sprintf("KP_%d_%s.pdf", saved.CartId, date)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании ссылки на КП: %w", err)
+	}
+
+	return &dto.DownloadURL{
+		URL:       url,
+		Filename:  filename,
+		Revision:  rev.Number,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// contentDisposition attachment с именем файла по RFC 6266: filename* в UTF-8 по RFC 5987
+// и ASCII-имя fallback для клиентов, не понимающих filename*
+func contentDisposition(filename, fallback string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, fallback, encoded.String())
+}
+
+// isAttrChar attr-char из RFC 5987: передается без процентного кодирования
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
+	"go.uber.org/zap"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// slugBytes 128 бит случайности: ссылку невозможно подобрать перебором
+	slugBytes         = 16
+	defaultPresignTTL = time.Minute
+	// maxHeaderLen сколько хранить от User-Agent и Referer
+	maxHeaderLen = 512
+)
+
+var (
+	// ErrPublicationGone ссылка отозвана или истекла, клиенту отдается как 410
+	ErrPublicationGone = errors.New("ссылка больше не действует")
+	ErrInvalidExpiry   = errors.New("срок действия ссылки уже истек")
+)
+
+// PublicationContent что отдать по публичной ссылке: поток PDF или адрес для редиректа
+type PublicationContent struct {
+	Body        io.ReadCloser
+	Size        int64
+	RedirectURL string
+	Publication *dto.Publication
+	// FirstView КП, если ссылку открыли впервые и об этом нужно уведомить
+	FirstView *dto.SavedPdf
+}
+
+// Viewer кто открыл ссылку
+type Viewer struct {
+	IP        string
+	UserAgent string
+	Referer   string
+}
+
+// Publish создает публичную ссылку на КП. Ссылка всегда ведет на последнюю ревизию
+func (s *PdfService) Publish(ctx context.Context, pdfId int64, req dto.PublishRequest) (*dto.Publication, error) {
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && s.publication.DefaultTTL > 0 {
+		t := time.Now().Add(s.publication.DefaultTTL)
+		expiresAt = &t
+	}
+	if expiresAt != nil {
+		if !expiresAt.After(time.Now()) {
+			return nil, ErrInvalidExpiry
+		}
+		t := expiresAt.UTC()
+		expiresAt = &t
+	}
+
+	slug, err := newSlug()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при публикации КП: %w", err)
+	}
+	pub, err := s.pdfRepo.CreatePublication(ctx, pdfId, slug, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при публикации КП: %w", err)
+	}
+	pub.URL = s.publicationURL(pub.Slug)
+
+	return pub, nil
+}
+
+func (s *PdfService) RevokePublication(ctx context.Context, slug string) error {
+	if err := s.pdfRepo.RevokePublication(ctx, slug); err != nil {
+		return fmt.Errorf("ошибка при отзыве публикации: %w", err)
+	}
+	return nil
+}
+
+// OpenPublication проверяет ссылку, открывает последнюю ревизию КП и записывает просмотр
+func (s *PdfService) OpenPublication(ctx context.Context, slug string, viewer Viewer) (*PublicationContent, error) {
+	pub, err := s.pdfRepo.GetPublication(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии публикации: %w", err)
+	}
+	if pub.Revoked || pub.ExpiresAt != nil && !pub.ExpiresAt.After(time.Now()) {
+		return nil, ErrPublicationGone
+	}
+
+	rev, err := s.pdfRepo.LatestRevision(ctx, pub.PdfID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии публикации: %w", err)
+	}
+
+	content := &PublicationContent{Publication: pub}
+	if s.publication.Redirect {
+		ttl := s.publication.PresignTTL
+		if ttl <= 0 {
+			ttl = defaultPresignTTL
+		}
+		content.RedirectURL, err = s.store.PresignGet(ctx, rev.Key, ttl, storage.PresignOptions{})
+	} else {
+		var obj *storage.Object
+		content.Body, obj, err = s.store.Get(ctx, rev.Key)
+		if err == nil {
+			content.Size = obj.Size
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при открытии публикации: %w", err)
+	}
+
+	s.recordView(ctx, content, viewer)
+	return content, nil
+}
+
+// recordView пишет просмотр в журнал. Ошибка записи не мешает клиенту открыть КП
+func (s *PdfService) recordView(ctx context.Context, content *PublicationContent, viewer Viewer) {
+	pub := content.Publication
+	first, err := s.pdfRepo.RecordView(ctx, pub, dto.View{
+		IPHash:    s.hashIP(viewer.IP),
+		UserAgent: truncate(viewer.UserAgent, maxHeaderLen),
+		Referer:   truncate(viewer.Referer, maxHeaderLen),
+	})
+	if err != nil {
+		s.logger.Error("ошибка записи просмотра", zap.Error(err), zap.String("slug", pub.Slug))
+		return
+	}
+	if !first || !s.publication.NotifyFirstView {
+		return
+	}
+
+	pdf, err := s.pdfRepo.GetByID(ctx, pub.PdfID)
+	if err != nil {
+		s.logger.Error("ошибка получения КП для уведомления", zap.Error(err), zap.String("slug", pub.Slug))
+		return
+	}
+	content.FirstView = pdf
+}
+
+func (s *PdfService) ViewStats(ctx context.Context, pdfId int64) (*dto.ViewStats, error) {
+	if _, err := s.pdfRepo.GetByID(ctx, pdfId); err != nil {
+		return nil, fmt.Errorf("ошибка при получении статистики КП: %w", err)
+	}
+	stats, err := s.pdfRepo.ViewStats(ctx, pdfId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении статистики КП: %w", err)
+	}
+	return stats, nil
+}
+
+// hashIP хеш IP с солью: позволяет считать уникальных зрителей, не храня адреса
+func (s *PdfService) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s.publication.IPHashSalt + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+func (s *PdfService) publicationURL(slug string) string {
+	return strings.TrimRight(s.publication.BaseURL, "/") + "/p/" + slug
+}
+
+func newSlug() (string, error) {
+	b := make([]byte, slugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -2,34 +2,40 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
 	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
 	"github.com/romapopov1212/robokp-pdf-service/internal/pdfgen"
 	"github.com/romapopov1212/robokp-pdf-service/internal/repository"
+	"github.com/romapopov1212/robokp-pdf-service/internal/storage"
 	"go.uber.org/zap"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 type PdfService struct {
-	pdfGen   *pdfgen.Page
-	pdfRepo  *repository.PdfRepository
-	logger   *zap.Logger
-	s3Client *s3.Client
+	pdfGen      *pdfgen.Page
+	pdfRepo     *repository.PdfRepository
+	logger      *zap.Logger
+	store       storage.Store
+	publication config.PublicationConfig
+	download    config.DownloadConfig
 }
 
-func NewPdfService(pdfRepo *repository.PdfRepository, logger *zap.Logger, s3Client *s3.Client, pdfGen *pdfgen.Page) *PdfService {
+func NewPdfService(pdfRepo *repository.PdfRepository, logger *zap.Logger, store storage.Store, pdfGen *pdfgen.Page, publication config.PublicationConfig, download config.DownloadConfig) *PdfService {
 	return &PdfService{
-		pdfRepo:  pdfRepo,
-		logger:   logger,
-		s3Client: s3Client,
-		pdfGen:   pdfGen,
+		pdfRepo:     pdfRepo,
+		logger:      logger,
+		store:       store,
+		pdfGen:      pdfGen,
+		publication: publication,
+		download:    download,
 	}
 }
 
 func (s *PdfService) SavePdf(
-	ctx context.Context, request dto.SavePdfRequest) error {
+	ctx context.Context, request dto.SavePdfRequest) (int64, error) {
 	
-	err := s.pdfRepo.Save(ctx,
+	id, err := s.pdfRepo.Save(ctx,
 		request.UserId,
 		request.CartId,
 		request.PublicationId,
@@ -40,7 +46,178 @@ func (s *PdfService) SavePdf(
 		request.Count)
 	if err != nil {
 		s.logger.Error("ошибка при сохранении пдф", zap.Error(err))
-		return fmt.Errorf("ошибка при сохрании: %w", err)
+		return 0, fmt.Errorf("ошибка при сохрании: %w", err)
+	}
+	return id, nil
+}
+
+func (s *PdfService) SaveExecutor(ctx context.Context, executor dto.Executor) error {
+	if err := s.pdfRepo.SaveExecutor(ctx, executor); err != nil {
+		s.logger.Error("ошибка при сохранении исполнителя", zap.Error(err))
+		return fmt.Errorf("ошибка при сохранении исполнителя: %w", err)
+	}
+	return nil
+}
+
+func (s *PdfService) GetExecutor(ctx context.Context, userId int64) (*dto.Executor, error) {
+	executor, err := s.pdfRepo.GetExecutor(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении исполнителя: %w", err)
+	}
+	return executor, nil
+}
+
+func (s *PdfService) GetPdf(ctx context.Context, id int64) (*dto.SavedPdf, error) {
+	pdf, err := s.pdfRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении КП: %w", err)
+	}
+	return pdf, nil
+}
+
+// ListPdf КП пользователя или корзины, если задан user_id - выборка по пользователю
+func (s *PdfService) ListPdf(ctx context.Context, filter dto.PdfFilter) (*dto.SavedPdfPage, error) {
+	var page *dto.SavedPdfPage
+	var err error
+	if filter.UserId != 0 {
+		page, err = s.pdfRepo.ListByUser(ctx, filter.UserId, filter)
+	} else {
+		page, err = s.pdfRepo.ListByCart(ctx, filter.CartId, filter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка КП: %w", err)
+	}
+	return page, nil
+}
+
+// UpdatePdf заменяет изменяемые поля КП целиком
+func (s *PdfService) UpdatePdf(ctx context.Context, id int64, version int, update dto.PdfUpdate) (*dto.SavedPdf, error) {
+	pdf, err := s.pdfRepo.Update(ctx, id, version, update)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении КП: %w", err)
+	}
+	return pdf, nil
+}
+
+// PatchPdf применяет JSON merge patch к изменяемым полям КП
+func (s *PdfService) PatchPdf(ctx context.Context, id int64, version int, patch json.RawMessage) (*dto.SavedPdf, error) {
+	current, err := s.pdfRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении КП: %w", err)
+	}
+	if current.Version != version {
+		return nil, fmt.Errorf("ошибка при обновлении КП: %w", repository.ErrVersionConflict)
+	}
+
+	target, err := json.Marshal(dto.PdfUpdate{
+		Logo:                   current.Logo,
+		ExecutorParameters:     current.ExecutorParameters,
+		PresentationParameters: current.PresentationParameters,
+		StyleTemplate:          current.StyleTemplate,
+		Count:                  current.Count,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении КП: %w", err)
+	}
+	merged, err := mergePatch(target, patch)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении КП: %w", err)
+	}
+	var update dto.PdfUpdate
+	if err := decodeStrict(merged, &update); err != nil {
+		return nil, fmt.Errorf("ошибка при обновлении КП: %w", err)
+	}
+
+	// Версия проверяется еще раз в UPDATE: между чтением и записью КП мог изменить другой запрос
+	return s.UpdatePdf(ctx, id, version, update)
+}
+
+func (s *PdfService) DeletePdf(ctx context.Context, id int64, version int) error {
+	if err := s.pdfRepo.Delete(ctx, id, version); err != nil {
+		return fmt.Errorf("ошибка при удалении КП: %w", err)
 	}
 	return nil
 }
+
+// Rendered результат генерации сохраненного КП
+type Rendered struct {
+	Pdf      *dto.SavedPdf
+	Revision *dto.Revision
+	Result   *pdfgen.Result
+}
+
+// RenderPdf генерирует PDF по сохраненной конфигурации КП, записывает его как новую ревизию
+// и сохраняет ключ в publication_url. При ошибке после чтения КП в Rendered заполнен только Pdf.
+func (s *PdfService) RenderPdf(ctx context.Context, id int64) (*Rendered, error) {
+	saved, err := s.pdfRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при генерации КП: %w", err)
+	}
+	
+	res, err := s.pdfGen.GenerateAdvancedPDFWithGofpdf(ctx, saved.SaveRequest)
+	if err != nil {
+		return &Rendered{Pdf: saved}, fmt.Errorf("ошибка при генерации КП: %w", err)
+	}
+	
+	// Снимок входных данных вместе с корзиной: без нее по ревизии не понять, что изменилось
+	snapshot := saved.SaveRequest
+	snapshot.Cart = res.Cart
+	input, err := json.Marshal(snapshot)
+	if err != nil {
+		return &Rendered{Pdf: saved}, fmt.Errorf("ошибка при сохранении ревизии КП: %w", err)
+	}
+	
+	updated, rev, err := s.pdfRepo.AddRevision(ctx, saved.Version, dto.Revision{
+		PdfID:      id,
+		Key:        res.Key,
+		SHA256:     res.SHA256,
+		Size:       res.Size,
+		Pages:      res.Pages,
+		TemplateID: res.TemplateID,
+		Input:      input,
+	})
+	if err != nil {
+		return &Rendered{Pdf: saved}, fmt.Errorf("ошибка при сохранении ревизии КП: %w", err)
+	}
+	return &Rendered{Pdf: updated, Revision: rev, Result: res}, nil
+}
+
+func (s *PdfService) ListRevisions(ctx context.Context, pdfId int64) ([]dto.Revision, error) {
+	if _, err := s.pdfRepo.GetByID(ctx, pdfId); err != nil {
+		return nil, fmt.Errorf("ошибка при получении ревизий КП: %w", err)
+	}
+	revisions, err := s.pdfRepo.ListRevisions(ctx, pdfId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ревизий КП: %w", err)
+	}
+	return revisions, nil
+}
+
+func (s *PdfService) GetRevision(ctx context.Context, pdfId int64, number int) (*dto.Revision, error) {
+	if _, err := s.pdfRepo.GetByID(ctx, pdfId); err != nil {
+		return nil, fmt.Errorf("ошибка при получении ревизии КП: %w", err)
+	}
+	rev, err := s.pdfRepo.GetRevision(ctx, pdfId, number)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ревизии КП: %w", err)
+	}
+	return rev, nil
+}
+
+// DiffRevisions сравнивает входные данные двух ревизий КП
+func (s *PdfService) DiffRevisions(ctx context.Context, pdfId int64, from, to int) (*dto.RevisionDiff, error) {
+	fromRev, err := s.GetRevision(ctx, pdfId, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.GetRevision(ctx, pdfId, to)
+	if err != nil {
+		return nil, err
+	}
+	
+	changes, err := diffJSON(fromRev.Input, toRev.Input)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сравнении ревизий КП: %w", err)
+	}
+	return &dto.RevisionDiff{From: from, To: to, Changes: changes}, nil
+}
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local хранилище в каталоге на диске, для запуска без MinIO. Ссылки PresignGet
+// ведут на BaseURL и подписываются HMAC, отдает их сам Local как http.Handler.
+// Метаданные объекта лежат рядом в файле .meta-<имя>.json.
+type Local struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+// minSecretLen минимальная длина ключа подписи ссылок в байтах
+const minSecretLen = 16
+
+func NewLocal(cfg config.LocalStorageConfig) (*Local, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("не задан каталог локального хранилища")
+	}
+	if len(cfg.Secret) < minSecretLen {
+		return nil, fmt.Errorf("ключ подписи локального хранилища короче %d байт", minSecretLen)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога хранилища: %w", err)
+	}
+	return &Local{
+		dir:     cfg.Dir,
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		secret:  []byte(cfg.Secret),
+	}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(cleaned)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("ошибка записи %q: %w", key, err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели недописанный объект
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("ошибка записи %q: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("ошибка записи %q: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ошибка записи %q: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("ошибка записи %q: %w", key, err)
+	}
+	// Метаданные пишутся после объекта: если запись не удалась, старый объект
+	// не останется с метаданными новой версии
+	if err := writeMetadata(p, lowerKeys(opts.Metadata)); err != nil {
+		return fmt.Errorf("ошибка записи метаданных %q: %w", key, err)
+	}
+	return nil
+}
+
+func metadataPath(p string) string {
+	return filepath.Join(filepath.Dir(p), ".meta-"+filepath.Base(p)+".json")
+}
+
+// writeMetadata пишет метаданные рядом с объектом, пустые - удаляют файл от прошлой версии
+func writeMetadata(p string, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		if err := os.Remove(metadataPath(p)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metadataPath(p), data, 0o644)
+}
+
+func readMetadata(p string) (map[string]string, error) {
+	data, err := os.ReadFile(metadataPath(p))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка чтения %q: %w", key, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("ошибка чтения %q: %w", key, err)
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	obj := localObject(key, info)
+	if obj.Metadata, err = readMetadata(p); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("ошибка чтения метаданных %q: %w", key, err)
+	}
+	return f, obj, nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %q: %w", key, err)
+	}
+	obj := localObject(key, info)
+	if obj.Metadata, err = readMetadata(p); err != nil {
+		return nil, fmt.Errorf("ошибка чтения метаданных %q: %w", key, err)
+	}
+	return obj, nil
+}
+
+func (l *Local) SetMetadata(ctx context.Context, key string, opts PutOptions) error {
+	if _, err := l.Stat(ctx, key); err != nil {
+		return err
+	}
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := writeMetadata(p, lowerKeys(opts.Metadata)); err != nil {
+		return fmt.Errorf("ошибка записи метаданных %q: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("ошибка удаления %q: %w", key, err)
+	}
+	if err := os.Remove(metadataPath(p)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("ошибка удаления метаданных %q: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(l.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Служебные файлы: недописанные объекты и метаданные
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") || strings.HasPrefix(d.Name(), ".meta-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *localObject(key, info))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка объектов: %w", err)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// PresignGet ссылка вида BaseURL/key?expires=<unix>&disposition=<...>&signature=<hmac>
+func (l *Local) PresignGet(ctx context.Context, key string, ttl time.Duration, opts PresignOptions) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	if opts.ContentDisposition != "" {
+		q.Set("disposition", opts.ContentDisposition)
+	}
+	q.Set("signature", l.sign(cleaned, expires, opts.ContentDisposition))
+	return l.baseURL + "/" + (&url.URL{Path: cleaned}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+func (l *Local) sign(key, expires, disposition string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key + "\n" + expires + "\n" + disposition))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP отдает объект по ссылке из PresignGet. Путь запроса - ключ объекта,
+// префикс маршрута снимается вызывающим (http.StripPrefix)
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, err := CleanKey(strings.TrimPrefix(r.URL.Path, "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	expires := r.URL.Query().Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	disposition := r.URL.Query().Get("disposition")
+	signature := r.URL.Query().Get("signature")
+	if err != nil || !hmac.Equal([]byte(signature), []byte(l.sign(key, expires, disposition))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > unix {
+		http.Error(w, "link expired", http.StatusForbidden)
+		return
+	}
+
+	body, obj, err := l.Get(r.Context(), key)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	if disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+	http.ServeContent(w, r, path.Base(key), obj.ModTime, body.(io.ReadSeeker))
+}
+
+func localObject(key string, info fs.FileInfo) *Object {
+	return &Object{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModTime:     info.ModTime(),
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory хранилище в памяти процесса, для тестов и разработки. Ссылки PresignGet
+// имеют схему memory:// и никуда не ведут
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	Object
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	if _, err := CleanKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("ошибка записи %q: %w", key, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{
+		data: data,
+		Object: Object{
+			Key:         key,
+			Size:        int64(len(data)),
+			ContentType: opts.ContentType,
+			ModTime:     time.Now(),
+			Metadata:    lowerKeys(opts.Metadata),
+		},
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	meta := obj.Object
+	return io.NopCloser(bytes.NewReader(obj.data)), &meta, nil
+}
+
+func (m *Memory) Stat(ctx context.Context, key string) (*Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	meta := obj.Object
+	return &meta, nil
+}
+
+func (m *Memory) SetMetadata(ctx context.Context, key string, opts PutOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	obj.ContentType = opts.ContentType
+	obj.Metadata = lowerKeys(opts.Metadata)
+	m.objects[key] = obj
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, prefix string) ([]Object, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var objects []Object
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			meta := obj.Object
+			meta.Metadata = nil
+			objects = append(objects, meta)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (m *Memory) PresignGet(ctx context.Context, key string, ttl time.Duration, opts PresignOptions) (string, error) {
+	if _, err := m.Stat(ctx, key); err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	if opts.ContentDisposition != "" {
+		q.Set("disposition", opts.ContentDisposition)
+	}
+	return "memory:///" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3 хранилище в бакете S3 или совместимом (MinIO). Put загружает через upload manager:
+// тело читается потоком и при размере больше части уходит multipart загрузкой
+type S3 struct {
+	client   *s3.Client
+	presign  *s3.PresignClient
+	uploader *manager.Uploader
+	bucket   string
+}
+
+func NewS3(client *s3.Client, bucket string) *S3 {
+	return &S3{
+		client:   client,
+		presign:  s3.NewPresignClient(client),
+		uploader: manager.NewUploader(client),
+		bucket:   bucket,
+	}
+}
+
+// NewS3FromConfig создает клиента по секции aws конфига
+func NewS3FromConfig(ctx context.Context, cfg config.AWSConfig) (*S3, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.Region),
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AccessKeyID,
+			cfg.SecretAccessKey,
+			"",
+		)))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки конфига AWS: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.EndpointUri != "" {
+			o.BaseEndpoint = aws.String(cfg.EndpointUri)
+		}
+		o.UsePathStyle = true
+	})
+	store := NewS3(client, cfg.Bucket)
+	store.uploader = manager.NewUploader(client, func(u *manager.Uploader) {
+		if cfg.UploadPartSizeMB > 0 {
+			u.PartSize = max(cfg.UploadPartSizeMB<<20, manager.MinUploadPartSize)
+		}
+		if cfg.UploadConcurrency > 0 {
+			u.Concurrency = cfg.UploadConcurrency
+		}
+	})
+	if cfg.PublicEndpoint != "" {
+		// Подпись включает хост, поэтому ссылки подписываются сразу на публичный адрес,
+		// а не переписываются после подписи
+		store.presign = s3.NewPresignClient(client, func(o *s3.PresignOptions) {
+			o.ClientOptions = append(o.ClientOptions, func(o *s3.Options) {
+				o.BaseEndpoint = aws.String(cfg.PublicEndpoint)
+			})
+		})
+	}
+	return store, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+	// При ошибке чтения тела или отмене ctx uploader прерывает multipart загрузку (AbortMultipartUpload),
+	// и загруженные части не остаются в бакете
+	if _, err := s.uploader.Upload(ctx, input); err != nil {
+		return fmt.Errorf("ошибка записи %q в S3: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка чтения %q из S3: %w", key, err)
+	}
+	return out.Body, &Object{
+		Key:         key,
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ModTime:     aws.ToTime(out.LastModified),
+		Metadata:    out.Metadata,
+	}, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*Object, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения %q из S3: %w", key, err)
+	}
+	return &Object{
+		Key:         key,
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ModTime:     aws.ToTime(out.LastModified),
+		Metadata:    out.Metadata,
+	}, nil
+}
+
+// SetMetadata копирует объект сам в себя с заменой метаданных, копирование идет на стороне S3
+func (s *S3) SetMetadata(ctx context.Context, key string, opts PutOptions) error {
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(copySource(s.bucket, key)),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		Metadata:          opts.Metadata,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	_, err := s.client.CopyObject(ctx, input)
+	if isNotFound(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка обновления метаданных %q в S3: %w", key, err)
+	}
+	return nil
+}
+
+// copySource bucket/key для CopyObject, каждый сегмент ключа экранируется отдельно
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("ошибка удаления %q из S3: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения списка объектов S3: %w", err)
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, Object{
+				Key:     aws.ToString(obj.Key),
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (s *S3) PresignGet(ctx context.Context, key string, ttl time.Duration, opts PresignOptions) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if opts.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ContentDisposition)
+	}
+	presigned, err := s.presign.PresignGetObject(ctx, input, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("ошибка при создании ссылки на %q: %w", key, err)
+	}
+	return presigned.URL, nil
+}
+
+// isNotFound HeadObject не возвращает NoSuchKey, только статус 404
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound" {
+		return true
+	}
+	var respErr interface{ HTTPStatusCode() int }
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound
+}
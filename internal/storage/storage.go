@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	BackendS3     = "s3"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+)
+
+var (
+	ErrNotFound   = errors.New("объект не найден")
+	ErrInvalidKey = errors.New("невалидный ключ объекта")
+)
+
+// Object метаданные объекта в хранилище
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	// Metadata пользовательские метаданные из PutOptions, ключи в нижнем регистре.
+	// List их не возвращает
+	Metadata map[string]string
+}
+
+type PutOptions struct {
+	ContentType string
+	Metadata    map[string]string
+}
+
+type PresignOptions struct {
+	// ContentDisposition заголовок, с которым хранилище отдаст объект по ссылке
+	ContentDisposition string
+}
+
+// Store хранилище сгенерированных PDF и загруженных картинок. Ключи - пути через "/"
+type Store interface {
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error
+	// Get открывает объект на чтение, вызывающий закрывает тело
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	// SetMetadata заменяет тип содержимого и метаданные объекта, не перезаписывая содержимое
+	SetMetadata(ctx context.Context, key string, opts PutOptions) error
+	// Delete удаляет объект, отсутствие объекта не ошибка
+	Delete(ctx context.Context, key string) error
+	// List объекты с ключом, начинающимся с prefix, в порядке возрастания ключа
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// PresignGet временная ссылка на скачивание объекта без авторизации
+	PresignGet(ctx context.Context, key string, ttl time.Duration, opts PresignOptions) (string, error)
+}
+
+// New создает хранилище по storage.backend, по умолчанию S3
+func New(ctx context.Context, cfg config.StorageConfig, awsCfg config.AWSConfig) (Store, error) {
+	switch cfg.Backend {
+	case "", BackendS3:
+		return NewS3FromConfig(ctx, awsCfg)
+	case BackendLocal:
+		return NewLocal(cfg.Local)
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище %q", cfg.Backend)
+	}
+}
+
+// CleanKey нормализует ключ и проверяет, что он относительный и не выходит за корень хранилища
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return cleaned, nil
+}
+
+// lowerKeys копия метаданных с ключами в нижнем регистре, как их возвращает S3
+func lowerKeys(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	lowered := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		lowered[strings.ToLower(k)] = v
+	}
+	return lowered
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBaseURL = "http://localhost:8082/storage"
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(config.LocalStorageConfig{Dir: t.TempDir(), BaseURL: testBaseURL + "/", Secret: "storage-test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// testStores хранилища, которые проверяются одними и теми же тестами
+func testStores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"local":  newTestLocal(t),
+		"memory": NewMemory(),
+	}
+}
+
+func put(t *testing.T, s Store, key, body string, metadata map[string]string) {
+	t.Helper()
+	if err := s.Put(context.Background(), key, strings.NewReader(body), PutOptions{ContentType: "application/pdf", Metadata: metadata}); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"pdfs/a.pdf", "pdfs/a.pdf"},
+		{"pdfs/./a.pdf", "pdfs/a.pdf"},
+		{"pdfs//a.pdf", "pdfs/a.pdf"},
+		{"pdfs/x/../a.pdf", "pdfs/a.pdf"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"../a.pdf", ""},
+		{"pdfs/../../a.pdf", ""},
+		{"/etc/passwd", ""},
+	}
+	for _, tt := range tests {
+		got, err := CleanKey(tt.key)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("CleanKey(%q) = %q, %v, want ErrInvalidKey", tt.key, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("CleanKey(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+func TestPutGetStat(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			put(t, s, "pdfs/a.pdf", "first", map[string]string{"SHA256": "abc"})
+
+			body, obj, err := s.Get(ctx, "pdfs/a.pdf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(body)
+			body.Close()
+			if string(data) != "first" || obj.Key != "pdfs/a.pdf" || obj.Size != 5 {
+				t.Errorf("Get() = %q, %+v", data, obj)
+			}
+			if want := map[string]string{"sha256": "abc"}; !reflect.DeepEqual(obj.Metadata, want) {
+				t.Errorf("Get() metadata %v, want %v", obj.Metadata, want)
+			}
+
+			// Перезапись без метаданных не оставляет метаданные прошлой версии
+			put(t, s, "pdfs/a.pdf", "second!", nil)
+			obj, err = s.Stat(ctx, "pdfs/a.pdf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.Size != 7 || len(obj.Metadata) != 0 {
+				t.Errorf("Stat() после перезаписи %+v", obj)
+			}
+
+			if err := s.SetMetadata(ctx, "pdfs/a.pdf", PutOptions{Metadata: map[string]string{"Sha256": "def"}}); err != nil {
+				t.Fatal(err)
+			}
+			if obj, err = s.Stat(ctx, "pdfs/a.pdf"); err != nil || obj.Metadata["sha256"] != "def" {
+				t.Errorf("Stat() после SetMetadata %+v, %v", obj, err)
+			}
+
+			for _, key := range []string{"pdfs/missing.pdf", "pdfs"} {
+				if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get(%q) error = %v, want ErrNotFound", key, err)
+				}
+				if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+					t.Errorf("Stat(%q) error = %v, want ErrNotFound", key, err)
+				}
+			}
+			if err := s.SetMetadata(ctx, "pdfs/missing.pdf", PutOptions{}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("SetMetadata() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestPutInvalidKey(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"", "/etc/passwd", "../a.pdf", "pdfs/../../a.pdf"} {
+				err := s.Put(context.Background(), key, strings.NewReader("x"), PutOptions{})
+				if !errors.Is(err, ErrInvalidKey) {
+					t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteList(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			put(t, s, "pdfs/b.pdf", "b", map[string]string{"sha256": "b"})
+			put(t, s, "pdfs/a.pdf", "a", nil)
+			put(t, s, "pdfs/sub/c.pdf", "c", nil)
+			put(t, s, "uploads/logo.png", "logo", nil)
+
+			objects, err := s.List(ctx, "pdfs/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, obj := range objects {
+				keys = append(keys, obj.Key)
+				if obj.Metadata != nil {
+					t.Errorf("List() вернул метаданные %s", obj.Key)
+				}
+			}
+			if want := []string{"pdfs/a.pdf", "pdfs/b.pdf", "pdfs/sub/c.pdf"}; !reflect.DeepEqual(keys, want) {
+				t.Errorf("List() = %v, want %v", keys, want)
+			}
+
+			if err := s.Delete(ctx, "pdfs/b.pdf"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Stat(ctx, "pdfs/b.pdf"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Stat() после Delete error = %v", err)
+			}
+			if err := s.Delete(ctx, "pdfs/b.pdf"); err != nil {
+				t.Errorf("повторный Delete() error = %v", err)
+			}
+			if objects, _ := s.List(ctx, "pdfs/"); len(objects) != 2 {
+				t.Errorf("List() после Delete %+v", objects)
+			}
+		})
+	}
+}
+
+func TestPresignGet(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			put(t, s, "pdfs/КП 1.pdf", "pdf", nil)
+
+			raw, err := s.PresignGet(ctx, "pdfs/КП 1.pdf", time.Hour, PresignOptions{ContentDisposition: `attachment; filename="kp.pdf"`})
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Path != "/pdfs/КП 1.pdf" && u.Path != "/storage/pdfs/КП 1.pdf" {
+				t.Errorf("PresignGet() path %q", u.Path)
+			}
+			if u.Query().Get("disposition") != `attachment; filename="kp.pdf"` || u.Query().Get("expires") == "" {
+				t.Errorf("PresignGet() query %q", u.RawQuery)
+			}
+			if _, err := s.PresignGet(ctx, "../a.pdf", time.Hour, PresignOptions{}); err == nil {
+				t.Error("PresignGet() для ключа вне хранилища должен вернуть ошибку")
+			}
+		})
+	}
+}
+
+func TestLocalMetadataFiles(t *testing.T) {
+	l := newTestLocal(t)
+	put(t, l, "pdfs/a.pdf", "a", map[string]string{"sha256": "a"})
+	if _, err := os.Stat(filepath.Join(l.dir, "pdfs", ".meta-a.pdf.json")); err != nil {
+		t.Fatalf("файл метаданных не записан: %v", err)
+	}
+	if err := l.Delete(context.Background(), "pdfs/a.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(l.dir, "pdfs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("после Delete остались файлы %v", entries)
+	}
+}
+
+func TestLocalServeHTTP(t *testing.T) {
+	l := newTestLocal(t)
+	put(t, l, "pdfs/a.pdf", "pdf", nil)
+	presign := func(key string, ttl time.Duration, disposition string) string {
+		raw, err := l.PresignGet(context.Background(), key, ttl, PresignOptions{ContentDisposition: disposition})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimPrefix(raw, testBaseURL)
+	}
+	valid := presign("pdfs/a.pdf", time.Hour, "attachment")
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"no signature", "/pdfs/a.pdf?expires=9999999999", http.StatusForbidden},
+		{"tampered signature", strings.Replace(valid, "signature=", "signature=00", 1), http.StatusForbidden},
+		{"tampered disposition", strings.Replace(valid, "disposition=attachment", "disposition=inline", 1), http.StatusForbidden},
+		{"tampered expires", strings.Replace(valid, "expires=", "expires=1", 1), http.StatusForbidden},
+		{"other key", strings.Replace(valid, "/pdfs/a.pdf", "/pdfs/b.pdf", 1), http.StatusForbidden},
+		{"expired", presign("pdfs/a.pdf", -time.Minute, ""), http.StatusForbidden},
+		{"missing", presign("pdfs/missing.pdf", time.Hour, ""), http.StatusNotFound},
+		{"traversal", "/../pdfs/a.pdf?" + strings.SplitN(valid, "?", 2)[1], http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			u, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Путь подставляется как есть: httptest.NewRequest очистил бы ".."
+			req.URL = u
+			rec := httptest.NewRecorder()
+			l.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if rec.Body.String() != "pdf" {
+				t.Errorf("тело %q", rec.Body)
+			}
+			if rec.Header().Get("Content-Disposition") != "attachment" || rec.Header().Get("Content-Type") != "application/pdf" {
+				t.Errorf("заголовки %v", rec.Header())
+			}
+		})
+	}
+}
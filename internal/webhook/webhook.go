@@ -0,0 +1,347 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/queue"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// KindDeliver тип задачи отправки уведомления в queue_task
+const KindDeliver = "webhook_deliver"
+
+// Заголовки запроса к получателю. Подпись считается как
+// hex(HMAC-SHA256(secret, timestamp + "." + body)) и передается в виде "sha256=<hex>".
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	defaultWorkers      = 1
+	defaultPollInterval = time.Second
+	defaultTimeout      = 10 * time.Second
+	// maxResponseSize сколько тела ответа дочитываем, чтобы переиспользовать соединение
+	maxResponseSize = 64 << 10
+)
+
+var (
+	ErrInvalidURL = errors.New("невалидный callback_url")
+	// ErrForbiddenAddress адрес получателя во внутренней сети: loopback, link-local, частные диапазоны
+	ErrForbiddenAddress = errors.New("адрес получателя во внутренней сети")
+)
+
+// Queue операции очереди задач, нужные отправке уведомлений, реализуется *queue.Queue
+type Queue interface {
+	Enqueue(ctx context.Context, kind string, payload any) (int64, error)
+	Claim(ctx context.Context, kind string) (*queue.Task, error)
+	Complete(ctx context.Context, task *queue.Task) error
+	Retry(ctx context.Context, task *queue.Task, cause error) (bool, error)
+	Dead(ctx context.Context, task *queue.Task, cause error) error
+}
+
+// DeliveryLog журнал попыток отправки
+type DeliveryLog interface {
+	SaveWebhookDelivery(ctx context.Context, d dto.WebhookDelivery) error
+}
+
+type task struct {
+	URL   string           `json:"url"`
+	Event dto.WebhookEvent `json:"event"`
+	JobID int64            `json:"job_id,omitempty"`
+	Body  json.RawMessage  `json:"body"`
+}
+
+// Notifier отправляет уведомления о завершении генерации. Отправка идет через очередь,
+// поэтому неудачные попытки повторяются с задержкой и переживают перезапуск сервиса.
+type Notifier struct {
+	queue        Queue
+	deliveries   DeliveryLog
+	logger       *zap.Logger
+	client       *http.Client
+	secret       []byte
+	defaultURL   string
+	allowPrivate bool
+	workers      int
+	pollInterval time.Duration
+	wg           sync.WaitGroup
+}
+
+func New(q Queue, deliveries DeliveryLog, logger *zap.Logger, cfg config.WebhookConfig) (*Notifier, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("не задан секрет подписи webhook")
+	}
+	n := &Notifier{
+		queue:        q,
+		deliveries:   deliveries,
+		logger:       logger,
+		secret:       []byte(cfg.Secret),
+		defaultURL:   cfg.DefaultURL,
+		allowPrivate: cfg.AllowPrivateNetworks,
+		workers:      cfg.Workers,
+		pollInterval: cfg.PollInterval,
+	}
+	if n.workers <= 0 {
+		n.workers = defaultWorkers
+	}
+	if n.pollInterval <= 0 {
+		n.pollInterval = defaultPollInterval
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !n.allowPrivate {
+		// Адрес проверяется после разрешения имени, непосредственно перед соединением,
+		// поэтому DNS rebinding между проверкой и отправкой не помогает
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isInternal(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		}
+	}
+	n.client = &http.Client{
+		Timeout: timeout,
+		// Без прокси из окружения: иначе проверяется адрес прокси, а не получателя
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+		// Редирект считается неудачной доставкой: подписанное тело не должно уходить на другой адрес
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return n, nil
+}
+
+// ValidateURL проверяет callback_url из запроса, пустой адрес допустим. Имя хоста
+// разрешается сразу, чтобы отклонить внутренний адрес до постановки в очередь;
+// при отправке адрес проверяется еще раз
+func (n *Notifier) ValidateURL(ctx context.Context, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidURL, raw)
+	}
+	if n.allowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: хост %q не найден", ErrInvalidURL, u.Hostname())
+	}
+	for _, addr := range addrs {
+		if isInternal(addr.IP) {
+			return fmt.Errorf("%w: %w: %s", ErrInvalidURL, ErrForbiddenAddress, addr.IP)
+		}
+	}
+	return nil
+}
+
+// cgnat разделяемое адресное пространство провайдеров 100.64.0.0/10
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isInternal адрес, на который нельзя отправлять уведомления с адресом от клиента
+func isInternal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || cgnat.Contains(ip)
+}
+
+// Sign подпись тела уведомления
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify ставит уведомление в очередь на отправку. Если callbackURL пуст, используется
+// адрес из конфига, если нет и его - уведомление не отправляется.
+func (n *Notifier) Notify(ctx context.Context, callbackURL string, payload dto.WebhookPayload) error {
+	target := callbackURL
+	if target == "" {
+		target = n.defaultURL
+	}
+	if target == "" {
+		return nil
+	}
+
+	if payload.OccurredAt.IsZero() {
+		payload.OccurredAt = time.Now().UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации уведомления: %w", err)
+	}
+
+	_, err = n.queue.Enqueue(ctx, KindDeliver, task{URL: target, Event: payload.Event, JobID: payload.JobID, Body: body})
+	return err
+}
+
+// Start запускает отправку уведомлений. Воркеры останавливаются при отмене ctx, Wait дожидается их завершения.
+func (n *Notifier) Start(ctx context.Context) {
+	for i := 0; i < n.workers; i++ {
+		n.wg.Add(1)
+		go n.work(ctx)
+	}
+}
+
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *Notifier) work(ctx context.Context) {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		qt, err := n.queue.Claim(ctx, KindDeliver)
+		if err != nil && ctx.Err() == nil {
+			n.logger.Error("ошибка получения уведомления из очереди", zap.Error(err))
+		}
+		if qt != nil {
+			n.run(ctx, qt)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Notifier) run(ctx context.Context, qt *queue.Task) {
+	logger := n.logger.With(zap.Int64("task_id", qt.ID), zap.Int("attempt", qt.Attempts))
+
+	var t task
+	if err := json.Unmarshal(qt.Payload, &t); err != nil {
+		logger.Error("ошибка разбора уведомления", zap.Error(err))
+		if err := n.queue.Dead(context.WithoutCancel(ctx), qt, err); err != nil {
+			logger.Error("ошибка перемещения уведомления в dead", zap.Error(err))
+		}
+		return
+	}
+	logger = logger.With(zap.String("url", t.URL), zap.String("event", string(t.Event)))
+
+	if qt.Exhausted {
+		logger.Error("уведомление не доставлено, попытки исчерпаны", zap.Error(queue.ErrLeaseExpired))
+		if err := n.queue.Dead(context.WithoutCancel(ctx), qt, queue.ErrLeaseExpired); err != nil {
+			logger.Error("ошибка перемещения уведомления в dead", zap.Error(err))
+		}
+		return
+	}
+
+	start := time.Now()
+	status, err := n.send(ctx, qt.ID, t)
+	if ctx.Err() != nil {
+		// Сервис останавливается: уведомление отправит другая реплика после таймаута видимости
+		return
+	}
+
+	delivery := dto.WebhookDelivery{
+		TaskID:     qt.ID,
+		JobID:      t.JobID,
+		Event:      t.Event,
+		URL:        t.URL,
+		Attempt:    qt.Attempts,
+		StatusCode: status,
+		Duration:   time.Since(start),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if err := n.deliveries.SaveWebhookDelivery(ctx, delivery); err != nil {
+		logger.Error("ошибка записи журнала уведомлений", zap.Error(err))
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		if err := n.queue.Complete(ctx, qt); err != nil {
+			logger.Error("ошибка завершения уведомления в очереди", zap.Error(err))
+		}
+		return
+	}
+
+	if errors.Is(err, ErrForbiddenAddress) {
+		// Адрес не станет разрешенным от повторов
+		logger.Error("уведомление не доставлено, адрес получателя запрещен", zap.Error(err))
+		if err := n.queue.Dead(ctx, qt, err); err != nil {
+			logger.Error("ошибка перемещения уведомления в dead", zap.Error(err))
+		}
+		return
+	}
+
+	retried, qerr := n.queue.Retry(ctx, qt, err)
+	if qerr != nil {
+		logger.Error("ошибка возврата уведомления в очередь", zap.Error(qerr))
+		return
+	}
+	if !retried {
+		logger.Error("уведомление не доставлено, попытки исчерпаны", zap.Error(err))
+		return
+	}
+	logger.Warn("уведомление не доставлено, будет повторено", zap.Error(err))
+}
+
+// send отправляет одно уведомление, успехом считается любой ответ 2xx
+func (n *Notifier) send(ctx context.Context, deliveryID int64, t task) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(t.Body))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(t.Event))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(deliveryID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(n.secret, timestamp, t.Body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отправки уведомления: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("получатель ответил %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
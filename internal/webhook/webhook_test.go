@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/romapopov1212/robokp-pdf-service/internal/config"
+	"github.com/romapopov1212/robokp-pdf-service/internal/dto"
+	"github.com/romapopov1212/robokp-pdf-service/internal/queue"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const testSecret = "webhook-test-secret"
+
+// fakeQueue очередь в памяти, запоминает, чем закончилась каждая задача
+type fakeQueue struct {
+	mu        sync.Mutex
+	pending   []*queue.Task
+	completed []int64
+	retried   []int64
+	dead      []int64
+}
+
+func (q *fakeQueue) Enqueue(ctx context.Context, kind string, payload any) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := int64(len(q.pending) + 1)
+	q.pending = append(q.pending, &queue.Task{ID: id, Kind: kind, Payload: data, Attempts: 1, MaxAttempts: 5})
+	return id, nil
+}
+
+func (q *fakeQueue) Claim(ctx context.Context, kind string) (*queue.Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return nil, nil
+	}
+	t := q.pending[0]
+	q.pending = q.pending[1:]
+	return t, nil
+}
+
+func (q *fakeQueue) Complete(ctx context.Context, task *queue.Task) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.completed = append(q.completed, task.ID)
+	return nil
+}
+
+func (q *fakeQueue) Retry(ctx context.Context, task *queue.Task, cause error) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retried = append(q.retried, task.ID)
+	return true, nil
+}
+
+func (q *fakeQueue) Dead(ctx context.Context, task *queue.Task, cause error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dead = append(q.dead, task.ID)
+	return nil
+}
+
+type fakeLog struct {
+	mu         sync.Mutex
+	deliveries []dto.WebhookDelivery
+}
+
+func (l *fakeLog) SaveWebhookDelivery(ctx context.Context, d dto.WebhookDelivery) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deliveries = append(l.deliveries, d)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, allowPrivate bool) (*Notifier, *fakeQueue, *fakeLog) {
+	t.Helper()
+	q, log := &fakeQueue{}, &fakeLog{}
+	n, err := New(q, log, zap.NewNop(), config.WebhookConfig{Secret: testSecret, AllowPrivateNetworks: allowPrivate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n, q, log
+}
+
+// deliver ставит уведомление в очередь и отправляет его один раз
+func deliver(t *testing.T, n *Notifier, q *fakeQueue, callbackURL string) {
+	t.Helper()
+	payload := dto.WebhookPayload{Event: dto.WebhookPdfSucceeded, JobID: 7}
+	if err := n.Notify(context.Background(), callbackURL, payload); err != nil {
+		t.Fatal(err)
+	}
+	task, err := q.Claim(context.Background(), KindDeliver)
+	if err != nil || task == nil {
+		t.Fatalf("уведомление не поставлено в очередь: %v", err)
+	}
+	n.run(context.Background(), task)
+}
+
+func TestDeliverySigned(t *testing.T) {
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		want := Sign([]byte(testSecret), r.Header.Get(HeaderTimestamp), body)
+		if got := r.Header.Get(HeaderSignature); got != want {
+			t.Errorf("подпись %q, want %q", got, want)
+		}
+		if got := r.Header.Get(HeaderEvent); got != string(dto.WebhookPdfSucceeded) {
+			t.Errorf("событие %q", got)
+		}
+		if got := r.Header.Get(HeaderDelivery); got != "1" {
+			t.Errorf("идентификатор доставки %q", got)
+		}
+		var payload dto.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil || payload.JobID != 7 {
+			t.Errorf("тело %s: %v", body, err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n, q, log := newTestNotifier(t, true)
+	deliver(t, n, q, srv.URL)
+
+	if received.Load() != 1 {
+		t.Fatalf("получатель вызван %d раз", received.Load())
+	}
+	if len(q.completed) != 1 || len(q.retried) != 0 {
+		t.Errorf("completed %v, retried %v", q.completed, q.retried)
+	}
+	if len(log.deliveries) != 1 || log.deliveries[0].StatusCode != http.StatusNoContent || log.deliveries[0].Error != "" {
+		t.Errorf("журнал %+v", log.deliveries)
+	}
+}
+
+func TestDeliveryRetriedOn5xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	n, q, log := newTestNotifier(t, true)
+	deliver(t, n, q, srv.URL)
+
+	if len(q.retried) != 1 || len(q.completed) != 0 || len(q.dead) != 0 {
+		t.Errorf("completed %v, retried %v, dead %v", q.completed, q.retried, q.dead)
+	}
+	if len(log.deliveries) != 1 || log.deliveries[0].StatusCode != http.StatusServiceUnavailable || log.deliveries[0].Error == "" {
+		t.Errorf("журнал %+v", log.deliveries)
+	}
+}
+
+func TestDeliveryRedirectNotFollowed(t *testing.T) {
+	var followed atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed.Add(1)
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	n, q, log := newTestNotifier(t, true)
+	deliver(t, n, q, srv.URL)
+
+	if followed.Load() != 0 {
+		t.Error("клиент перешел по редиректу")
+	}
+	if len(q.retried) != 1 || len(q.completed) != 0 {
+		t.Errorf("completed %v, retried %v", q.completed, q.retried)
+	}
+	if len(log.deliveries) != 1 || log.deliveries[0].StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("журнал %+v", log.deliveries)
+	}
+}
+
+func TestDeliveryExhaustedDropped(t *testing.T) {
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer srv.Close()
+
+	n, q, log := newTestNotifier(t, true)
+	if err := n.Notify(context.Background(), srv.URL, dto.WebhookPayload{Event: dto.WebhookPdfFailed, JobID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	task, _ := q.Claim(context.Background(), KindDeliver)
+	// Таймаут видимости истек на последней попытке
+	task.Attempts, task.Exhausted = task.MaxAttempts+1, true
+	n.run(context.Background(), task)
+
+	if received.Load() != 0 {
+		t.Error("исчерпанное уведомление отправлено")
+	}
+	if len(q.dead) != 1 || len(q.retried) != 0 || len(q.completed) != 0 {
+		t.Errorf("completed %v, retried %v, dead %v", q.completed, q.retried, q.dead)
+	}
+	if len(log.deliveries) != 0 {
+		t.Errorf("журнал %+v", log.deliveries)
+	}
+}
+
+func TestDeliveryInternalAddressBlocked(t *testing.T) {
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer srv.Close()
+
+	n, q, _ := newTestNotifier(t, false)
+	if err := n.ValidateURL(context.Background(), srv.URL); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("ValidateURL(%q) error = %v, want ErrForbiddenAddress", srv.URL, err)
+	}
+
+	// Notify адрес не проверяет: внутренний адрес отсекается при соединении
+	deliver(t, n, q, srv.URL)
+	if received.Load() != 0 {
+		t.Error("уведомление отправлено на loopback")
+	}
+	if len(q.dead) != 1 || len(q.retried) != 0 {
+		t.Errorf("retried %v, dead %v", q.retried, q.dead)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	n, _, _ := newTestNotifier(t, false)
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"", nil},
+		{"ftp://example.com/hook", ErrInvalidURL},
+		{"http:///hook", ErrInvalidURL},
+		{"http://127.0.0.1:8080/hook", ErrForbiddenAddress},
+		{"http://[::1]/hook", ErrForbiddenAddress},
+		{"http://169.254.169.254/latest/meta-data", ErrForbiddenAddress},
+		{"http://10.0.0.5/hook", ErrForbiddenAddress},
+		{"http://192.168.1.1/hook", ErrForbiddenAddress},
+		{"http://100.64.0.1/hook", ErrForbiddenAddress},
+		{"http://0.0.0.0/hook", ErrForbiddenAddress},
+		{"https://93.184.216.34/hook", nil},
+	}
+	for _, tt := range tests {
+		err := n.ValidateURL(context.Background(), tt.url)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("ValidateURL(%q) error = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestNewRequiresSecret(t *testing.T) {
+	if _, err := New(&fakeQueue{}, &fakeLog{}, zap.NewNop(), config.WebhookConfig{}); err == nil {
+		t.Error("New() без секрета должен вернуть ошибку")
+	}
+}
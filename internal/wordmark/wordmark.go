@@ -0,0 +1,118 @@
+package wordmark
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+)
+
+var ErrEmptyText = errors.New("пустой текст логотипа")
+
+const (
+	// maxFontSize верхняя граница кегля в пикселях, чтобы короткий текст не раздувался на всю область
+	maxFontSize = 512.0
+	// underlineThickness толщина подчеркивания относительно кегля
+	underlineThickness = 0.06
+)
+
+// Options параметры текстового логотипа
+type Options struct {
+	Text      string
+	Font      []byte // TTF нужного начертания (regular, bold, italic, bold italic)
+	Underline bool
+	Color     color.Color
+	// Width и Height размер области в пикселях, в которую вписывается текст
+	Width  int
+	Height int
+}
+
+// Render растеризует текстовый логотип на прозрачном фоне с подбором кегля под область.
+// Размер результата - по фактическому тексту, не больше области.
+func Render(opts Options) (*image.RGBA, error) {
+	text := strings.TrimSpace(opts.Text)
+	if text == "" {
+		return nil, ErrEmptyText
+	}
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("нулевой размер области логотипа")
+	}
+	if opts.Color == nil {
+		opts.Color = color.Black
+	}
+
+	f, err := opentype.Parse(opts.Font)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора шрифта: %w", err)
+	}
+
+	size, err := fitSize(f, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	face, err := newFace(f, size)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := font.MeasureString(face, text).Ceil()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+	if width == 0 || height == 0 {
+		return nil, ErrEmptyText
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(opts.Color),
+		Face: face,
+		Dot:  fixed.Point26_6{X: 0, Y: metrics.Ascent},
+	}
+	d.DrawString(text)
+
+	if opts.Underline {
+		thickness := int(math.Max(1, math.Round(size*underlineThickness)))
+		y := metrics.Ascent.Ceil() + (metrics.Descent.Ceil()-thickness)/2
+		draw.Draw(img, image.Rect(0, y, width, y+thickness), image.NewUniform(opts.Color), image.Point{}, draw.Over)
+	}
+
+	return img, nil
+}
+
+// fitSize подбирает наибольший кегль, при котором текст помещается в область
+func fitSize(f *opentype.Font, text string, opts Options) (float64, error) {
+	// Ширина и высота строки растут линейно с кеглем, поэтому достаточно одного замера
+	const probe = 100.0
+	face, err := newFace(f, probe)
+	if err != nil {
+		return 0, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := float64(font.MeasureString(face, text)) / 64
+	height := float64(metrics.Ascent+metrics.Descent) / 64
+	if width == 0 || height == 0 {
+		return 0, ErrEmptyText
+	}
+
+	size := probe * math.Min(float64(opts.Width)/width, float64(opts.Height)/height)
+	// Небольшой запас на округление метрик хинтинга
+	return math.Min(size*0.98, maxFontSize), nil
+}
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания шрифта: %w", err)
+	}
+	return face, nil
+}